@@ -66,5 +66,10 @@ func runGoals(platformRoot, layersRoot, buildpackRoot string) error {
 		return err
 	}
 
+	_, err = jdkInstaller.InstallToolchains(appDir, layersDir)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
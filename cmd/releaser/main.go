@@ -2,14 +2,21 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/buildpack/libbuildpack/layers"
 	"github.com/buildpack/libbuildpack/logger"
 	"github.com/heroku/java-buildpack/cmd"
+	"github.com/heroku/java-buildpack/jdk"
+	"github.com/heroku/java-buildpack/launch"
+	"github.com/heroku/java-buildpack/maven"
 	"github.com/heroku/java-buildpack/procfile"
+	"github.com/heroku/java-buildpack/sbom"
 	"github.com/heroku/java-buildpack/util"
+	"github.com/heroku/java-buildpack/war"
 )
 
 var (
@@ -36,38 +43,283 @@ func writeLaunchMetadata(launchDir string) error {
 	}
 
 	log := logger.DefaultLogger()
+	layersDir := layers.NewLayers(launchDir, log)
 
-	processes, err := procfile.Parse(filepath.Join(appDir, "Procfile"))
+	if err := writeSbom(appDir, layersDir, log); err != nil {
+		return err
+	}
+
+	procfileProcesses, err := procfile.Parse(filepath.Join(appDir, "Procfile"))
+	if err == procfile.ErrNotFound {
+		log.Debug("%s", err)
+	} else if err != nil {
+		return err
+	}
+	fromProcfile := launch.FromLayers(procfileProcesses, launch.SourceProcfile)
+
+	// layers extracted from a Spring Boot jar by the previous build are only kept if the jar is exploded again
+	if err := util.RemoveSpringBootLayers(layersDir); err != nil {
+		return err
+	}
+
+	// a web process from the Procfile replaces the detected one, which doesn't need its layers then
+	_, hasWeb := fromProcfile.Find("web")
+
+	deployedWar := false
+	detected, artifact, err := findExecutableJar(appDir, layersDir, !hasWeb, log)
 	if err != nil {
-		log.Debug(err.Error())
-	} else {
-		logProcessTypes(processes, log)
-		return writeMetadata(launchDir, processes, log)
+		log.Debug("%s", err)
+		if !hasWeb {
+			if detected, artifact, err = findWar(appDir, layersDir, log); err != nil {
+				return err
+			}
+			deployedWar = len(detected) > 0
+		}
 	}
 
-	processes, err = util.FindExecutableJar(appDir)
+	// the runner installed by a previous build is only kept if a war is deployed again
+	if !deployedWar {
+		if err := war.Remove(layersDir); err != nil {
+			return err
+		}
+	}
+
+	processes, err := resolveProcesses(launch.Merge(fromProcfile, detected), layersDir, log)
 	if err != nil {
-		log.Debug(err.Error())
+		return err
+	}
+	if len(processes) == 0 {
+		log.Info("No process types detected")
 	} else {
-		logProcessTypes(processes, log)
-		return writeMetadata(launchDir, processes, log)
+		for _, warning := range processes.Warnings() {
+			log.Info("WARNING: %s", warning)
+		}
+		log.Info("Process types:")
+		for _, line := range processes.Table() {
+			log.Info("  %s", line)
+		}
 	}
 
-	log.Info("No process types detected")
-	return nil
-}
+	slices, err := launch.Slices(appDir, buildModules(layersDir, log))
+	if err != nil {
+		return err
+	}
 
-func writeMetadata(layersRoot string, processes layers.Processes, log logger.Logger) error {
-	layersDir := layers.NewLayers(layersRoot, log)
+	app := findApp(appDir, artifact, log)
+	if err := launch.WriteAppEnv(layersDir, app); err != nil {
+		return err
+	}
 
-	return layersDir.WriteMetadata(layers.Metadata{
+	labels := launchLabels(appDir, layersDir, log)
+	if app.ArtifactId != "" {
+		labels = append(labels, launch.AppLabels(app)...)
+	}
+
+	return launch.Write(layersDir, launch.Metadata{
 		Processes: processes,
+		Labels:    labels,
+		Slices:    slices,
 	})
 }
 
-func logProcessTypes(processes layers.Processes, log logger.Logger) {
-	log.Info("Discovered process type(s):")
-	for _, p := range processes {
-		log.Info("  %s: %s\n", p.Type, p.Command)
+// resolveProcesses rewrites the commands for RESOLVE_PROCESS_COMMANDS and DIRECT_PROCESSES.
+func resolveProcesses(processes launch.Processes, layersDir layers.Layers, log logger.Logger) (launch.Processes, error) {
+	if resolveCommands() {
+		jvm, err := jdk.FindLaunchJvm(layersDir)
+		if err != nil {
+			return nil, err
+		}
+		if jvm.Home == "" {
+			log.Info("WARNING: no JVM in the launch image, the process commands are left as they are")
+		} else {
+			processes = processes.ResolveCommands(jvm.Home)
+		}
+	}
+
+	if directProcesses() {
+		processes = processes.Direct()
+	}
+	return processes, nil
+}
+
+// findApp reads the Maven coordinates of the launched artifact from its pom.properties.
+func findApp(appDir, artifact string, log logger.Logger) util.PomProperties {
+	if artifact == "" {
+		return util.PomProperties{}
+	}
+
+	app, source, err := util.FindPomProperties(appDir, artifact)
+	if err != nil {
+		log.Debug("%s", err)
+		return util.PomProperties{}
+	}
+	log.Info("App: %s (from %s)", app.Coordinates(), source)
+	return app
+}
+
+// launchLabels describe the JVM and the build tool.
+func launchLabels(appDir string, layersDir layers.Layers, log logger.Logger) []launch.Label {
+	var labels []launch.Label
+
+	if jvm, err := jdk.FindLaunchJvm(layersDir); err != nil {
+		log.Debug("%s", err)
+	} else if jvm.Home != "" {
+		labels = append(labels,
+			launch.Label{Key: "io.heroku.java.jvm.vendor", Value: jvm.Version.Vendor},
+			launch.Label{Key: "io.heroku.java.jvm.version", Value: jvm.Version.Tag})
+	}
+
+	if distribution, err := maven.FindDistribution(appDir, layersDir); err != nil {
+		log.Debug("%s", err)
+	} else if distribution.Version != "" {
+		labels = append(labels, launch.Label{Key: "io.heroku.java.build-tool", Value: "maven " + distribution.Version})
+	}
+	return labels
+}
+
+// buildModules lists the paths of the modules in the Maven build, or only the app directory if the reactor is
+// unknown.
+func buildModules(layersDir layers.Layers, log logger.Logger) []string {
+	buildMetadata, err := maven.ReadBuildMetadata(layersDir)
+	if err != nil {
+		log.Debug("%s", err)
+	}
+
+	var modules []string
+	for _, module := range buildMetadata.Modules {
+		modules = append(modules, module.Path)
+	}
+	if len(modules) == 0 {
+		modules = []string{"."}
 	}
+	return modules
+}
+
+// resolveCommands is true when RESOLVE_PROCESS_COMMANDS opts in to commands that don't rely on the profile scripts.
+func resolveCommands() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("RESOLVE_PROCESS_COMMANDS"))
+	return enabled
+}
+
+// directProcesses is true when DIRECT_PROCESSES opts in to running the processes that don't need a shell directly.
+func directProcesses() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DIRECT_PROCESSES"))
+	return enabled
+}
+
+// findExecutableJar checks the modules that produced artifacts in the Maven build, starting with the ones selected
+// with MAVEN_PROJECTS, and falls back to the app's own target directory.
+func findExecutableJar(appDir string, layersDir layers.Layers, explode bool, log logger.Logger) (launch.Processes, string, error) {
+	buildMetadata, err := maven.ReadBuildMetadata(layersDir)
+	if err != nil {
+		log.Debug("%s", err)
+	}
+
+	for _, module := range buildMetadata.DeployableModules() {
+		candidate, err := util.SelectJar(appDir, module.Path, log)
+		if err != nil {
+			log.Debug("%s", err)
+		} else {
+			if module.Path != "." {
+				log.Info("Using executable jar from module %s (%s)", module.ArtifactId, module.Path)
+			}
+			return jarProcesses(appDir, candidate, layersDir, explode, log), candidate.Path, nil
+		}
+	}
+
+	candidate, err := util.SelectJar(appDir, ".", log)
+	if err != nil {
+		return nil, "", err
+	}
+	return jarProcesses(appDir, candidate, layersDir, explode, log), candidate.Path, nil
+}
+
+// findWar deploys a war without a Main-Class with a servlet runner, which is installed into a launch layer. It returns
+// no processes when there is no such war.
+func findWar(appDir string, layersDir layers.Layers, log logger.Logger) (launch.Processes, string, error) {
+	buildMetadata, err := maven.ReadBuildMetadata(layersDir)
+	if err != nil {
+		log.Debug("%s", err)
+	}
+
+	modules := append(buildMetadata.DeployableModules(), maven.Module{Path: "."})
+	for _, module := range modules {
+		candidate, err := util.FindWar(appDir, module.Path)
+		if err != nil {
+			log.Debug("%s", err)
+			continue
+		}
+
+		runner, err := war.Config()
+		if err != nil {
+			return nil, "", err
+		}
+		if runner, err = war.Install(runner, layersDir, log); err != nil {
+			return nil, "", err
+		}
+		log.Info("Deploying %s with %s %s", candidate.Path, runner.Name, runner.Version)
+		if configured, _ := util.ReadMainClassProcesses(appDir); len(configured) > 0 {
+			log.Info("WARNING: the process types in system.properties are ignored for WAR files")
+		}
+		source := fmt.Sprintf("%s %s", runner.Name, runner.Version)
+		return launch.FromLayers(layers.Processes{runner.Process(candidate)}, source), candidate.Path, nil
+	}
+	return nil, "", nil
+}
+
+// jarProcesses launches layered Spring Boot jars from their exploded layers, so that a change to the app doesn't
+// invalidate the image layer with its dependencies, and runs every other jar with java -jar. The process types set
+// with process.* in system.properties run their main class from the same jar.
+func jarProcesses(appDir string, candidate util.JarCandidate, layersDir layers.Layers, explode bool, log logger.Logger) launch.Processes {
+	configured, err := util.ReadMainClassProcesses(appDir)
+	if err != nil {
+		log.Info("WARNING: ignoring the process types in system.properties: %s", err.Error())
+	}
+
+	var processes layers.Processes
+	if candidate.IsSpringBoot() && explode {
+		exploded, err := util.ExplodeSpringBootJar(appDir, candidate, layersDir)
+		if err == nil {
+			log.Info("Exploded the layers of %s into launch layers", candidate.Path)
+			processes = util.WithMainClassProcesses(layers.Processes{exploded.Process()}, configured, exploded.MainClassProcess)
+		} else {
+			log.Info("Running %s with java -jar: %s", candidate.Path, err.Error())
+		}
+	}
+	if processes == nil {
+		processes = util.WithMainClassProcesses(layers.Processes{candidate.Process()}, configured, candidate.MainClassProcess)
+	}
+
+	var result launch.Processes
+	for _, process := range launch.FromLayers(processes, candidate.Path) {
+		for _, mainClassProcess := range configured {
+			if mainClassProcess.Type == process.Type {
+				process.Source = "system.properties"
+			}
+		}
+		result = append(result, process)
+	}
+	return result
+}
+
+// writeSbom adds the software bill of materials to the image, in the formats selected with SBOM_FORMATS.
+func writeSbom(appDir string, layersDir layers.Layers, log logger.Logger) error {
+	formats, err := sbom.Formats()
+	if err != nil {
+		return err
+	}
+
+	bom, err := sbom.Collect(appDir, layersDir)
+	if err != nil {
+		return err
+	}
+
+	files, err := sbom.Write(bom, formats, layersDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		log.Info("SBOM written to %s", file)
+	}
+	return nil
 }
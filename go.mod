@@ -2,6 +2,7 @@ module github.com/heroku/java-buildpack
 
 require (
 	bou.ke/monkey v1.0.1 // indirect
+	github.com/BurntSushi/toml v0.3.1
 	github.com/bouk/monkey v1.0.1 // indirect
 	github.com/buildpack/libbuildpack v1.6.0
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -14,5 +15,4 @@ require (
 	github.com/sclevine/spec v1.2.0
 	github.com/stretchr/testify v1.2.2 // indirect
 	golang.org/x/sys v0.0.0-20181212120007-b05ddf57801d // indirect
-	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,157 @@
+package jdk
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/heroku/java-buildpack/util"
+)
+
+type versionSource struct {
+	Name string
+	// Explicit sources name a JDK on purpose, so a bad value fails the build instead of being skipped.
+	Explicit bool
+	Detect   func(appDir string) (string, bool, error)
+}
+
+// versionSources are checked in order, and the first one that declares a version wins.
+var versionSources = []versionSource{
+	{Name: "system.properties", Explicit: true, Detect: detectSystemPropertiesVersion},
+	{Name: ".java-version", Explicit: true, Detect: detectJavaVersionFileVersion},
+	{Name: ".tool-versions", Explicit: true, Detect: detectToolVersionsVersion},
+	{Name: "pom.xml", Explicit: false, Detect: detectPomVersion},
+}
+
+// pomVersionProperties are the pom.xml properties that imply a JDK version, in order of preference.
+var pomVersionProperties = []string{
+	"maven.compiler.release",
+	"maven.compiler.target",
+	"maven.compiler.source",
+	"java.version",
+}
+
+// plainVersion matches version numbers such as 17 or 1.8, unlike unresolved property references.
+var plainVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+func (i *Installer) detectVersion(appDir string) (Version, error) {
+	if err := i.validateSystemProperties(appDir); err != nil {
+		return Version{}, err
+	}
+
+	for _, source := range versionSources {
+		v, found, err := source.Detect(appDir)
+		if err != nil {
+			if source.Explicit {
+				return Version{}, failedToReadVersionFile(source.Name, err)
+			}
+			i.Log.Debug("unable to read %s: %s", source.Name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		version, err := parseVersionHint(v)
+		if err != nil {
+			// a plain version number is a JDK the app needs, even from a source that's only a hint
+			if source.Explicit || plainVersion.MatchString(v) {
+				return Version{}, unsupportedJdkVersion(v, source.Name, err)
+			}
+			i.Log.Info("Ignoring JDK version %s from %s: %s", v, source.Name, err)
+			continue
+		}
+
+		i.Log.Info("Using JDK %s from %s", version.Tag, source.Name)
+		return version, nil
+	}
+
+	version := defaultVersion()
+	i.Log.Info("No JDK version specified, using default JDK %s", version.Tag)
+	return version, nil
+}
+
+// parseVersionHint accepts anything ParseVersionString does, plus the vendor-prefixed versions used by tools like
+// asdf and jenv (e.g. "temurin-11.0.3+7" or "adoptopenjdk-11"), which are resolved to our build of that major version.
+func parseVersionHint(v string) (Version, error) {
+	version, err := ParseVersionString(v)
+	if err == nil {
+		return version, nil
+	}
+
+	if m := regexp.MustCompile("^[a-z]+-(1\\.)?([0-9]+)").FindStringSubmatch(v); m != nil {
+		if _, ok := DefaultVersionStrings[m[2]]; ok {
+			return ParseVersionString(m[2])
+		}
+	}
+	return Version{}, err
+}
+
+func detectSystemPropertiesVersion(appDir string) (string, bool, error) {
+	systemPropertiesFile := filepath.Join(appDir, "system.properties")
+	if _, err := os.Stat(systemPropertiesFile); os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	sysProps, err := util.ReadPropertiesFile(systemPropertiesFile)
+	if err != nil {
+		return "", false, err
+	}
+
+	version, ok := sysProps["java.runtime.version"]
+	return strings.TrimSpace(version), ok, nil
+}
+
+func detectJavaVersionFileVersion(appDir string) (string, bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(appDir, ".java-version"))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	version := strings.TrimSpace(string(data))
+	return version, version != "", nil
+}
+
+func detectToolVersionsVersion(appDir string) (string, bool, error) {
+	file, err := os.Open(filepath.Join(appDir, ".tool-versions"))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// asdf allows fallback versions after the first one, but we only install one JDK
+		if len(fields) >= 2 && fields[0] == "java" {
+			return fields[1], true, nil
+		}
+	}
+	return "", false, scanner.Err()
+}
+
+func detectPomVersion(appDir string) (string, bool, error) {
+	pomFile := filepath.Join(appDir, "pom.xml")
+	if _, err := os.Stat(pomFile); os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	pom, err := util.ReadPomFile(pomFile)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, name := range pomVersionProperties {
+		if version, ok := pom.Property(name); ok && version != "" {
+			return version, true, nil
+		}
+	}
+	return "", false, nil
+}
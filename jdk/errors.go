@@ -22,3 +22,15 @@ func errorWithCause(message string, cause error) error {
 func invalidJdkVersion(version string, url string) error {
 	return errorWithCause(fmt.Sprintf("Invalid JDK version: %s", version), errors.New(fmt.Sprintf("Failed to reach %s", url)))
 }
+
+func invalidSystemProperties(cause error) error {
+	return errorWithCause("Invalid system.properties file", cause)
+}
+
+func unsupportedJdkVersion(version string, source string, cause error) error {
+	return errorWithCause(fmt.Sprintf("Unsupported JDK version %s in %s", version, source), cause)
+}
+
+func failedToReadVersionFile(file string, cause error) error {
+	return errorWithCause(fmt.Sprintf("Failed to read JDK version from %s", file), cause)
+}
@@ -10,10 +10,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/buildpack/libbuildpack/layers"
 	"github.com/buildpack/libbuildpack/logger"
-	"github.com/heroku/java-buildpack/util"
 )
 
 type Installer struct {
@@ -27,6 +27,9 @@ type Installer struct {
 type Jvm struct {
 	Version Version `toml:"version"`
 	Home    string  `toml:"home"`
+	// Url and Sha256 identify the archive the JVM was installed from
+	Url    string `toml:"url"`
+	Sha256 string `toml:"sha256"`
 }
 
 type Version struct {
@@ -84,6 +87,7 @@ func (i *Installer) Install(appDir string, layersDir layers.Layers) (Jvm, error)
 	jdk := Jvm{
 		Home:    jdkLayer.Root,
 		Version: i.Version,
+		Url:     jdkUrl,
 	}
 
 	// check to see if there is an existing cache layer with the same Version.Tag as the one we need to install.
@@ -107,7 +111,7 @@ func (i *Installer) Install(appDir string, layersDir layers.Layers) (Jvm, error)
 		i.Log.Debug("no cached JDK detected")
 	}
 
-	if err := i.fetchJdk(jdkUrl, jdkLayer); err != nil {
+	if jdk.Sha256, err = i.fetchJdk(jdkUrl, jdkLayer); err != nil {
 		return jdk, err
 	}
 
@@ -147,6 +151,8 @@ func (i *Installer) Install(appDir string, layersDir layers.Layers) (Jvm, error)
 		jre := Jvm{
 			Home:    jreLayer.Root,
 			Version: i.Version,
+			Url:     jdk.Url,
+			Sha256:  jdk.Sha256,
 		}
 		if err := jdkLayer.WriteMetadata(jdk, layers.Cache, layers.Build); err != nil {
 			return jdk, err
@@ -160,13 +166,26 @@ func (i *Installer) Install(appDir string, layersDir layers.Layers) (Jvm, error)
 	return jdk, nil
 }
 
-func (i *Installer) fetchJdk(jdkUrl string, layer layers.Layer) error {
-	cmd := exec.Command(filepath.Join("jdk-fetcher"), jdkUrl, layer.Root)
+// fetchJdk downloads and extracts the JDK into the layer, and returns the SHA-256 checksum of the archive.
+func (i *Installer) fetchJdk(jdkUrl string, layer layers.Layer) (string, error) {
+	checksumFile, err := ioutil.TempFile("", "jdk-sha256")
+	if err != nil {
+		return "", err
+	}
+	checksumFile.Close()
+	defer os.Remove(checksumFile.Name())
+
+	cmd := exec.Command(filepath.Join("jdk-fetcher"), jdkUrl, layer.Root, checksumFile.Name())
 	cmd.Env = os.Environ()
 	cmd.Stdout = i.Out
 	cmd.Stderr = i.Err
 
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	checksum, err := ioutil.ReadFile(checksumFile.Name())
+	return strings.TrimSpace(string(checksum)), err
 }
 
 func (i *Installer) removeLayer(layer layers.Layer) error {
@@ -202,19 +221,18 @@ func (i *Installer) applyJdkOverlay(layer layers.Layer, appDir string) error {
 	return cmd.Run()
 }
 
-func (i *Installer) detectVersion(appDir string) (Version, error) {
-	systemPropertiesFile := filepath.Join(appDir, "system.properties")
-	if _, err := os.Stat(systemPropertiesFile); !os.IsNotExist(err) {
-		sysProps, err := util.ReadPropertiesFile(systemPropertiesFile)
-		if err != nil {
-			return defaultVersion(), nil
-		}
-
-		if version, ok := sysProps["java.runtime.version"]; ok {
-			return ParseVersionString(version)
-		}
+// FindLaunchJvm returns the JVM in the launch image, which is the JRE when one was extracted from the JDK, and the
+// JDK otherwise. Its Home is empty when no JVM was installed.
+func FindLaunchJvm(layersDir layers.Layers) (Jvm, error) {
+	var jre, jdk Jvm
+	if err := layersDir.Layer("jre").ReadMetadata(&jre); err != nil {
+		return jre, err
+	}
+	if jre.Home != "" {
+		return jre, nil
 	}
-	return defaultVersion(), nil
+	err := layersDir.Layer("jdk").ReadMetadata(&jdk)
+	return jdk, err
 }
 
 func InstallCerts(jdk Jvm) error {
@@ -264,8 +282,8 @@ func defaultVersion() Version {
 }
 
 func ParseVersionString(v string) (Version, error) {
-	if v == "10" || v == "11" {
-		return ParseVersionString(DefaultVersionStrings[v])
+	if tag, ok := DefaultVersionStrings[v]; ok {
+		return ParseVersionString(tag)
 	} else if m := regexp.MustCompile("^(1[0-9])\\.").FindAllStringSubmatch(v, -1); len(m) == 1 {
 		major := m[0][1]
 		return Version{
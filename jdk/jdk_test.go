@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/buildpack/libbuildpack/layers"
@@ -103,6 +104,35 @@ func testJdkInstaller(t *testing.T, when spec.G, it spec.S) {
 		_ = os.RemoveAll(layersDir.Root)
 	})
 
+	when("#FindLaunchJvm", func() {
+		it("should prefer the JRE", func() {
+			jdkMetadata := jdk.Jvm{Home: layersDir.Layer("jdk").Root}
+			if err := layersDir.Layer("jdk").WriteMetadata(jdkMetadata, layers.Build, layers.Cache); err != nil {
+				t.Fatal(err)
+			}
+
+			jvm, err := jdk.FindLaunchJvm(layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jvm.Home != jdkMetadata.Home {
+				t.Fatalf(`Did not fall back to the JDK: got %s, want %s`, jvm.Home, jdkMetadata.Home)
+			}
+
+			jreMetadata := jdk.Jvm{Home: layersDir.Layer("jre").Root}
+			if err := layersDir.Layer("jre").WriteMetadata(jreMetadata, layers.Launch); err != nil {
+				t.Fatal(err)
+			}
+
+			if jvm, err = jdk.FindLaunchJvm(layersDir); err != nil {
+				t.Fatal(err)
+			}
+			if jvm.Home != jreMetadata.Home {
+				t.Fatalf(`Did not find the JRE: got %s, want %s`, jvm.Home, jreMetadata.Home)
+			}
+		})
+	})
+
 	when("#Init", func() {
 		it("should detect jdk version", func() {
 			err := installer.Init(fixture("app_with_jdk_version"))
@@ -115,6 +145,91 @@ func testJdkInstaller(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf(`JDK version did not match: got %s, want %s`, installer.Version.Tag, expected)
 			}
 		})
+
+		it("should detect jdk version from every source", func() {
+			for _, app := range []string{
+				"app_with_pom_release",
+				"app_with_java_version_file",
+				"app_with_tool_versions",
+				"app_with_trailing_space_system_properties",
+				"app_with_vendor_system_properties",
+			} {
+				if err := installer.Init(fixture(app)); err != nil {
+					t.Fatalf(`%s: %s`, app, err)
+				}
+
+				expected := jdk.DefaultVersionStrings["11"]
+				if installer.Version.Tag != expected {
+					t.Fatalf(`%s: JDK version did not match: got %s, want %s`, app, installer.Version.Tag, expected)
+				}
+			}
+		})
+
+		it("should fail on an unsupported version from pom.xml", func() {
+			err := installer.Init(fixture("app_with_pom_unsupported_release"))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+
+			expected := "Unsupported JDK version 17 in pom.xml"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf(`Error did not match: got %s, want %s`, err.Error(), expected)
+			}
+		})
+
+		it("should fail on a misspelled java.runtime.version", func() {
+			err := installer.Init(fixture("app_with_invalid_system_properties"))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+
+			expected := "system.properties line 2: unknown property java.runtime.verison (did you mean java.runtime.version?)"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf(`Error did not match: got %s, want %s`, err.Error(), expected)
+			}
+		})
+
+		it("should warn about unknown system properties", func() {
+			err := installer.Init(fixture("app_with_unknown_system_properties"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := jdk.DefaultVersionStrings["11"]
+			if installer.Version.Tag != expected {
+				t.Fatalf(`JDK version did not match: got %s, want %s`, installer.Version.Tag, expected)
+			}
+		})
+
+		it("should accept process types", func() {
+			if err := installer.Init(fixture("app_with_process_types")); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		it("should fail on invalid process types", func() {
+			err := installer.Init(fixture("app_with_invalid_process_types"))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+
+			expected := `system.properties line 2: invalid process type "worker.1" in process.worker.1`
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf(`Error did not match: got %s, want %s`, err.Error(), expected)
+			}
+		})
+
+		it("should use the default jdk version", func() {
+			err := installer.Init(fixture("app_with_pom"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := jdk.DefaultVersionStrings[jdk.DefaultJdkMajorVersion]
+			if installer.Version.Tag != expected {
+				t.Fatalf(`JDK version did not match: got %s, want %s`, installer.Version.Tag, expected)
+			}
+		})
 	})
 
 	when("#GetVersionUrl", func() {
@@ -0,0 +1,201 @@
+package jdk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/java-buildpack/util"
+)
+
+const javaRuntimeVersionKey = "java.runtime.version"
+
+var knownSystemProperties = []string{
+	javaRuntimeVersionKey,
+	javaToolchainVersionsKey,
+}
+
+type propertyProblem struct {
+	Line    int
+	Message string
+	// Fatal problems fail the build, everything else is reported as a warning.
+	Fatal bool
+}
+
+func (p propertyProblem) String() string {
+	return fmt.Sprintf("system.properties line %d: %s", p.Line, p.Message)
+}
+
+func (i *Installer) validateSystemProperties(appDir string) error {
+	problems, err := checkSystemProperties(filepath.Join(appDir, "system.properties"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return invalidSystemProperties(err)
+	}
+
+	var fatal []string
+	for _, p := range problems {
+		if p.Fatal {
+			fatal = append(fatal, p.String())
+		} else {
+			i.Log.Info("WARNING: %s", p)
+		}
+	}
+
+	if len(fatal) > 0 {
+		return invalidSystemProperties(errors.New(strings.Join(fatal, "\n  ")))
+	}
+	return nil
+}
+
+func checkSystemProperties(file string) ([]propertyProblem, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var problems []propertyProblem
+	seen := map[string]int{}
+	typos := map[string]int{}
+
+	scanner := util.NewPropertiesScanner(fh)
+	for scanner.Scan() {
+		property := scanner.Property()
+		key, value, lineNumber := property.Key, strings.TrimSpace(property.Value), property.Line
+
+		if previous, ok := seen[key]; ok {
+			problems = append(problems, propertyProblem{
+				Line:    lineNumber,
+				Message: fmt.Sprintf("%s overrides the value set on line %d", key, previous),
+			})
+		}
+		seen[key] = lineNumber
+
+		switch {
+		case key == javaRuntimeVersionKey:
+			if value == "" {
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("%s is empty", key),
+					Fatal:   true,
+				})
+			} else if _, err := parseVersionHint(value); err != nil {
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("unsupported %s %q", key, value),
+					Fatal:   true,
+				})
+			}
+		case key == javaToolchainVersionsKey:
+			for _, v := range parseToolchainVersions(value) {
+				if _, err := parseVersionHint(v); err != nil {
+					problems = append(problems, propertyProblem{
+						Line:    lineNumber,
+						Message: fmt.Sprintf("unsupported JDK version %q in %s", v, key),
+						Fatal:   true,
+					})
+				}
+			}
+		case strings.HasPrefix(key, util.ProcessKeyPrefix):
+			processType := strings.TrimPrefix(key, util.ProcessKeyPrefix)
+			if !util.IsValidProcessType(processType) {
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("invalid process type %q in %s, use only letters, digits, - and _", processType, key),
+					Fatal:   true,
+				})
+			} else if !util.IsValidClassName(value) {
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("%s is not a main class: %q", key, value),
+					Fatal:   true,
+				})
+			}
+		case isKnownSystemProperty(key):
+		default:
+			if suggestion, ok := suggestSystemProperty(key); ok {
+				typos[suggestion] = len(problems)
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("unknown property %s (did you mean %s?)", key, suggestion),
+				})
+			} else {
+				problems = append(problems, propertyProblem{
+					Line:    lineNumber,
+					Message: fmt.Sprintf("unknown property %s will be ignored", key),
+				})
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if perr, ok := err.(*util.PropertiesError); ok {
+			return append(problems, propertyProblem{Line: perr.Line, Message: perr.Err.Error(), Fatal: true}), nil
+		}
+		return nil, err
+	}
+
+	// a misspelled key is only a warning when the correct one is also there, otherwise the setting is silently lost
+	for key, index := range typos {
+		if _, ok := seen[key]; !ok {
+			problems[index].Fatal = true
+		}
+	}
+
+	return problems, nil
+}
+
+func isKnownSystemProperty(key string) bool {
+	for _, known := range knownSystemProperties {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+func suggestSystemProperty(key string) (string, bool) {
+	for _, known := range knownSystemProperties {
+		if editDistance(strings.ToLower(key), known) <= 2 {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// editDistance is the Damerau-Levenshtein distance (with adjacent transpositions) between a and b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
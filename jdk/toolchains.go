@@ -0,0 +1,155 @@
+package jdk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+)
+
+const (
+	javaToolchainVersionsKey = "java.toolchain.versions"
+	toolchainLayerPrefix     = "toolchain-jdk-"
+)
+
+// InstallToolchains installs the extra JDKs listed in the java.toolchain.versions system property, each into its own
+// build-only layer, so that they can be offered to the maven-toolchains-plugin. Layers for JDKs that are no longer
+// listed are removed.
+func (i *Installer) InstallToolchains(appDir string, layersDir layers.Layers) ([]Jvm, error) {
+	versions, err := detectToolchainVersions(appDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var toolchains []Jvm
+	wanted := map[string]bool{}
+	for _, version := range versions {
+		if version.Major == i.Version.Major || wanted[version.Major] {
+			continue
+		}
+		wanted[version.Major] = true
+
+		toolchain, err := i.installToolchain(version, layersDir.Layer(toolchainLayerPrefix+version.Major))
+		if err != nil {
+			return toolchains, err
+		}
+		toolchains = append(toolchains, toolchain)
+	}
+
+	existing, err := filepath.Glob(filepath.Join(layersDir.Root, toolchainLayerPrefix+"*.toml"))
+	if err != nil {
+		return toolchains, err
+	}
+	for _, metadata := range existing {
+		major := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(metadata), toolchainLayerPrefix), ".toml")
+		if !wanted[major] {
+			i.Log.Debug("removing unused JDK %s toolchain from cache", major)
+			if err := i.removeLayer(layersDir.Layer(toolchainLayerPrefix + major)); err != nil {
+				return toolchains, err
+			}
+		}
+	}
+
+	return toolchains, nil
+}
+
+func (i *Installer) installToolchain(version Version, layer layers.Layer) (Jvm, error) {
+	jdk := Jvm{
+		Home:    layer.Root,
+		Version: version,
+	}
+
+	var cached Jvm
+	if err := layer.ReadMetadata(&cached); err == nil && cached.Version.Tag == version.Tag {
+		i.Log.Info("JDK %s toolchain installed from cache", version.Tag)
+		return cached, nil
+	}
+	if err := i.removeLayer(layer); err != nil {
+		return jdk, err
+	}
+
+	jdkUrl, err := GetVersionUrl(version)
+	if err != nil {
+		return jdk, err
+	}
+
+	if !IsValidJdkUrl(jdkUrl) {
+		return jdk, invalidJdkVersion(version.Tag, jdkUrl)
+	}
+
+	jdk.Url = jdkUrl
+	if jdk.Sha256, err = i.fetchJdk(jdkUrl, layer); err != nil {
+		return jdk, err
+	}
+
+	if err := InstallCerts(jdk); err != nil {
+		return jdk, err
+	}
+
+	if err := layer.WriteMetadata(jdk, layers.Build, layers.Cache); err != nil {
+		return jdk, err
+	}
+
+	i.Log.Info("JDK %s toolchain installed", version.Tag)
+	return jdk, nil
+}
+
+// FindInstalledJdks returns the JDK installed for the build along with any toolchain JDKs, by reading the metadata of
+// their layers.
+func FindInstalledJdks(layersDir layers.Layers) ([]Jvm, error) {
+	names := []string{"jdk"}
+
+	toolchains, err := filepath.Glob(filepath.Join(layersDir.Root, toolchainLayerPrefix+"*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	for _, metadata := range toolchains {
+		names = append(names, strings.TrimSuffix(filepath.Base(metadata), ".toml"))
+	}
+
+	var jdks []Jvm
+	for _, name := range names {
+		var jdk Jvm
+		if err := layersDir.Layer(name).ReadMetadata(&jdk); err != nil {
+			return nil, err
+		}
+		if jdk.Home != "" {
+			jdks = append(jdks, jdk)
+		}
+	}
+	return jdks, nil
+}
+
+func detectToolchainVersions(appDir string) ([]Version, error) {
+	systemPropertiesFile := filepath.Join(appDir, "system.properties")
+	if _, err := os.Stat(systemPropertiesFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	sysProps, err := util.ReadPropertiesFile(systemPropertiesFile)
+	if err != nil {
+		return nil, failedToReadVersionFile("system.properties", err)
+	}
+
+	var versions []Version
+	for _, v := range parseToolchainVersions(strings.TrimSpace(sysProps[javaToolchainVersionsKey])) {
+		version, err := parseVersionHint(v)
+		if err != nil {
+			return nil, unsupportedJdkVersion(v, "system.properties", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+func parseToolchainVersions(value string) []string {
+	var versions []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
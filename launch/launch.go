@@ -0,0 +1,242 @@
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+)
+
+// SourceProcfile is the source of the process types defined in the Procfile, which take precedence over the detected
+// ones.
+const SourceProcfile = "Procfile"
+
+// Process is a process type in launch.toml. Unlike layers.Process, it can run directly, and it knows where it came
+// from. Processes can only be marked as the default from Buildpack API 0.6, so the launcher runs web unless it's given
+// another type.
+type Process struct {
+	Type    string   `toml:"type"`
+	Command string   `toml:"command"`
+	Args    []string `toml:"args,omitempty"`
+	// Direct processes are run without a shell, so the command is only the executable, and the profile scripts
+	// don't run
+	Direct bool `toml:"direct,omitempty"`
+	// Source is where the process type came from, and is only used for logging
+	Source string `toml:"-"`
+}
+
+type Processes []Process
+
+// Label is an image label.
+type Label struct {
+	Key   string `toml:"key"`
+	Value string `toml:"value"`
+}
+
+// Slice is a group of files in the app directory that is stored in its own image layer, so that it can be reused
+// when other files change. Paths are globs relative to the app directory.
+type Slice struct {
+	Paths []string `toml:"paths"`
+}
+
+// Metadata is the content of launch.toml. Labels need Buildpack API 0.3, which buildpack.toml declares.
+type Metadata struct {
+	Processes Processes `toml:"processes"`
+	Labels    []Label   `toml:"labels,omitempty"`
+	Slices    []Slice   `toml:"slices,omitempty"`
+}
+
+// FromLayers gives the same source to every process.
+func FromLayers(processes layers.Processes, source string) Processes {
+	var result Processes
+	for _, process := range processes {
+		result = append(result, Process{Type: process.Type, Command: process.Command, Source: source})
+	}
+	return result
+}
+
+// Merge combines the process types from the Procfile with the detected ones, which are only kept when the Procfile
+// doesn't define the same type.
+func Merge(procfile, detected Processes) Processes {
+	merged := append(Processes{}, procfile...)
+	for _, process := range detected {
+		if _, ok := merged.Find(process.Type); !ok {
+			merged = append(merged, process)
+		}
+	}
+	return merged
+}
+
+// Find returns the process of a type.
+func (p Processes) Find(processType string) (Process, bool) {
+	for _, process := range p {
+		if process.Type == processType {
+			return process, true
+		}
+	}
+	return Process{}, false
+}
+
+var (
+	portReference     = regexp.MustCompile(`\$(PORT\b|\{PORT\})`)
+	javaOptsReference = regexp.MustCompile(`\$(JAVA_OPTS\b|\{JAVA_OPTS\})`)
+)
+
+// Warnings points out a web process from the Procfile that doesn't listen on $PORT, which the router sends requests
+// to, and process types that won't start by default since none of them is web. Detected web processes always listen
+// on $PORT.
+func (p Processes) Warnings() []string {
+	var warnings []string
+	if web, ok := p.Find("web"); !ok && len(p) == 1 {
+		warnings = append(warnings, fmt.Sprintf("the only process type, %s, isn't web, so it doesn't start by default", p[0].Type))
+	} else if !ok && len(p) > 1 {
+		warnings = append(warnings, "there is no web process, so no process type starts by default")
+	} else if ok && web.Source == SourceProcfile && !portReference.MatchString(web.Command) {
+		warnings = append(warnings, "the web process in the Procfile doesn't reference $PORT, so it might not receive requests")
+	}
+	return warnings
+}
+
+// Table lists the process types, where they came from, and their commands.
+func (p Processes) Table() []string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tSOURCE\tCOMMAND")
+	for _, process := range p {
+		processType := process.Type
+		if process.Type == "web" {
+			processType += " (default)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", processType, process.Source, strings.Join(append([]string{process.Command}, process.Args...), " "))
+	}
+	writer.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// ResolveCommands rewrites the detected java commands so that they don't depend on the java in the PATH: java is run
+// from the JVM's home, with $JAVA_OPTS unless the command already has it, and replaces the shell so that it receives signals. The commands need a shell
+// to expand $JAVA_OPTS, which holds both the defaults from profile.d/jvm.sh and any options set at runtime, so the JVM
+// defaults only come from that script. Commands from the Procfile are left as they are.
+func (p Processes) ResolveCommands(javaHome string) Processes {
+	java := filepath.Join(javaHome, "bin", "java")
+
+	var resolved Processes
+	for _, process := range p {
+		if process.Source != SourceProcfile && strings.HasPrefix(process.Command, "java ") {
+			args := strings.TrimPrefix(process.Command, "java ")
+			if !javaOptsReference.MatchString(args) {
+				args = "$JAVA_OPTS " + args
+			}
+			process.Command = fmt.Sprintf("exec %s %s", java, args)
+		}
+		resolved = append(resolved, process)
+	}
+	return resolved
+}
+
+// shellSyntax are the characters that make a command need a shell, to expand variables, quotes, globs or redirections.
+const shellSyntax = "$\"'`\\|&;<>(){}*?~#"
+
+// Direct runs the detected processes without a shell when their command doesn't need one, which rules out any that
+// reference $PORT. Commands from the Procfile are always run with a shell.
+func (p Processes) Direct() Processes {
+	var direct Processes
+	for _, process := range p {
+		if process.Source != SourceProcfile && !process.Direct && !strings.ContainsAny(process.Command, shellSyntax) {
+			fields := strings.Fields(process.Command)
+			process.Command, process.Args, process.Direct = fields[0], fields[1:], true
+		}
+		direct = append(direct, process)
+	}
+	return direct
+}
+
+// Slices separates the jars that a module's build copied into target/dependency or target/lib from its classes, with
+// snapshots in a slice of their own since they change more often than releases. Only the slices that match files are
+// returned. Modules are paths relative to the app directory.
+func Slices(appDir string, modules []string) ([]Slice, error) {
+	var releases, snapshots, classes Slice
+	for _, module := range modules {
+		for _, dir := range []string{"dependency", "lib"} {
+			jars, err := filepath.Glob(filepath.Join(appDir, module, "target", dir, "*.jar"))
+			if err != nil {
+				return nil, err
+			}
+			for _, jar := range jars {
+				rel, err := filepath.Rel(appDir, jar)
+				if err != nil {
+					return nil, err
+				}
+				if strings.HasSuffix(jar, "-SNAPSHOT.jar") {
+					snapshots.Paths = append(snapshots.Paths, rel)
+				} else {
+					releases.Paths = append(releases.Paths, rel)
+				}
+			}
+		}
+
+		if info, err := os.Stat(filepath.Join(appDir, module, "target", "classes")); err == nil && info.IsDir() {
+			classes.Paths = append(classes.Paths, filepath.Join(module, "target", "classes"))
+		}
+	}
+
+	var slices []Slice
+	for _, slice := range []Slice{releases, snapshots, classes} {
+		if len(slice.Paths) > 0 {
+			slices = append(slices, slice)
+		}
+	}
+	return slices, nil
+}
+
+const appLayerName = "app"
+
+// AppLabels label the image with the Maven coordinates of the app.
+func AppLabels(app util.PomProperties) []Label {
+	return []Label{
+		{Key: "io.heroku.java.app.group-id", Value: app.GroupId},
+		{Key: "io.heroku.java.app.artifact-id", Value: app.ArtifactId},
+		{Key: "io.heroku.java.app.version", Value: app.Version},
+	}
+}
+
+// WriteAppEnv sets APP_VERSION and APP_ARTIFACT_ID at launch, with a layer that only holds the environment. Without
+// coordinates, the layer is removed so that the previous image's values don't linger.
+func WriteAppEnv(layersDir layers.Layers, app util.PomProperties) error {
+	layer := layersDir.Layer(appLayerName)
+	if err := layer.RemoveMetadata(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(layer.Root); err != nil {
+		return err
+	}
+	if app.ArtifactId == "" {
+		return nil
+	}
+
+	if err := layer.OverrideLaunchEnv("APP_VERSION", "%s", app.Version); err != nil {
+		return err
+	}
+	if err := layer.OverrideLaunchEnv("APP_ARTIFACT_ID", "%s", app.ArtifactId); err != nil {
+		return err
+	}
+	return layer.WriteMetadata(app, layers.Launch)
+}
+
+// Write replaces the launch.toml in the layers directory.
+func Write(layersDir layers.Layers, metadata Metadata) error {
+	file, err := os.OpenFile(filepath.Join(layersDir.Root, "launch.toml"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return toml.NewEncoder(file).Encode(metadata)
+}
@@ -0,0 +1,251 @@
+package launch_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
+	"github.com/google/go-cmp/cmp"
+	"github.com/heroku/java-buildpack/launch"
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLaunch(t *testing.T) {
+	spec.Run(t, "Launch", testLaunch, spec.Report(report.Terminal{}))
+}
+
+func testLaunch(t *testing.T, when spec.G, it spec.S) {
+	detected := launch.Processes{
+		{Type: "web", Command: "java -Dserver.port=$PORT -jar target/app.jar", Source: "target/app.jar"},
+		{Type: "worker", Command: "java -cp target/app.jar com.example.Worker", Source: "system.properties"},
+	}
+
+	when("#Merge", func() {
+		it("should let the Procfile win for each process type", func() {
+			procfile := launch.Processes{
+				{Type: "worker", Command: "bash bin/worker.sh", Source: launch.SourceProcfile},
+				{Type: "release", Command: "bash bin/release.sh", Source: launch.SourceProcfile},
+			}
+
+			processes := launch.Merge(procfile, detected)
+
+			expected := launch.Processes{
+				{Type: "worker", Command: "bash bin/worker.sh", Source: launch.SourceProcfile},
+				{Type: "release", Command: "bash bin/release.sh", Source: launch.SourceProcfile},
+				{Type: "web", Command: "java -Dserver.port=$PORT -jar target/app.jar", Source: "target/app.jar"},
+			}
+			if diff := cmp.Diff(processes, expected); diff != "" {
+				t.Fatalf(`Process types did not match: (-got +want)\n%s`, diff)
+			}
+		})
+	})
+
+	when("#Warnings", func() {
+		it("should warn about a web process in the Procfile without $PORT", func() {
+			processes := launch.Merge(launch.Processes{
+				{Type: "web", Command: "java -jar target/app.jar", Source: launch.SourceProcfile},
+			}, detected)
+
+			if len(processes.Warnings()) != 1 {
+				t.Fatalf(`Did not warn about $PORT: %v`, processes.Warnings())
+			}
+
+			processes[0].Command = "java -Dserver.port=${PORT} -jar target/app.jar"
+			if len(processes.Warnings()) != 0 {
+				t.Fatalf(`Did not expect warnings: %v`, processes.Warnings())
+			}
+		})
+
+		it("should warn that the only process doesn't start by default unless it's web", func() {
+			processes := launch.Merge(nil, detected[1:])
+
+			if len(processes.Warnings()) != 1 || !strings.Contains(processes.Warnings()[0], "worker") {
+				t.Fatalf(`Did not warn about the only process: %v`, processes.Warnings())
+			}
+
+			if warnings := launch.Merge(nil, detected[:1]).Warnings(); len(warnings) != 0 {
+				t.Fatalf(`Did not expect warnings: %v`, warnings)
+			}
+		})
+	})
+
+	when("#Table", func() {
+		it("should list the process types with their source", func() {
+			lines := launch.Merge(nil, detected).Table()
+
+			expected := []string{
+				"TYPE           SOURCE             COMMAND",
+				"web (default)  target/app.jar     java -Dserver.port=$PORT -jar target/app.jar",
+				"worker         system.properties  java -cp target/app.jar com.example.Worker",
+			}
+			if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+				t.Fatalf("Did not format the table: got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+			}
+		})
+	})
+
+	when("#ResolveCommands", func() {
+		it("should run the detected java commands from the JVM's home", func() {
+			processes := launch.Merge(launch.Processes{
+				{Type: "release", Command: "java -cp target/app.jar com.example.Release", Source: launch.SourceProcfile},
+			}, append(detected, launch.Process{
+				Type: "exploded", Command: "java $JAVA_OPTS -cp /layers/app com.example.Main", Source: "target/app.jar",
+			})).ResolveCommands("/layers/heroku_java/jre")
+
+			expected := launch.Processes{
+				{Type: "release", Command: "java -cp target/app.jar com.example.Release", Source: launch.SourceProcfile},
+				{Type: "web", Command: "exec /layers/heroku_java/jre/bin/java $JAVA_OPTS -Dserver.port=$PORT -jar target/app.jar", Source: "target/app.jar"},
+				{Type: "worker", Command: "exec /layers/heroku_java/jre/bin/java $JAVA_OPTS -cp target/app.jar com.example.Worker", Source: "system.properties"},
+				{Type: "exploded", Command: "exec /layers/heroku_java/jre/bin/java $JAVA_OPTS -cp /layers/app com.example.Main", Source: "target/app.jar"},
+			}
+			if diff := cmp.Diff(processes, expected); diff != "" {
+				t.Fatalf(`Did not resolve the commands: (-got +want)\n%s`, diff)
+			}
+		})
+	})
+
+	when("#Direct", func() {
+		it("should run the detected commands that don't need a shell directly", func() {
+			processes := launch.Merge(launch.Processes{
+				{Type: "release", Command: "java -cp target/app.jar com.example.Release", Source: launch.SourceProcfile},
+			}, detected).Direct()
+
+			if processes[0].Direct || processes[1].Direct {
+				t.Fatalf(`Did not expect direct processes: %v`, processes[:2])
+			}
+
+			worker := processes[2]
+			if !worker.Direct || worker.Command != "java" || strings.Join(worker.Args, " ") != "-cp target/app.jar com.example.Worker" {
+				t.Fatalf(`Did not run the worker directly: %v`, worker)
+			}
+		})
+	})
+
+	when("#Slices", func() {
+		it("should separate dependencies and snapshots from classes", func() {
+			appDir, err := ioutil.TempDir("", "app")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(appDir)
+
+			for _, file := range []string{
+				"target/dependency/slf4j-api-1.7.32.jar",
+				"service/target/lib/commons-lang3-3.12.0.jar",
+				"service/target/lib/common-1.0-SNAPSHOT.jar",
+				"service/target/classes/com/example/Main.class",
+			} {
+				path := filepath.Join(appDir, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			slices, err := launch.Slices(appDir, []string{".", "common", "service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := [][]string{
+				{"target/dependency/slf4j-api-1.7.32.jar", "service/target/lib/commons-lang3-3.12.0.jar"},
+				{"service/target/lib/common-1.0-SNAPSHOT.jar"},
+				{"service/target/classes"},
+			}
+			if len(slices) != len(expected) {
+				t.Fatalf(`Did not find the slices: got %v, want %v`, slices, expected)
+			}
+			for i := range expected {
+				if strings.Join(slices[i].Paths, " ") != strings.Join(expected[i], " ") {
+					t.Fatalf(`Did not find the slice: got %v, want %v`, slices[i].Paths, expected[i])
+				}
+			}
+		})
+	})
+
+	when("#WriteAppEnv", func() {
+		it("should set the app's coordinates at launch", func() {
+			root, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			layersDir := layers.NewLayers(root, logger.Logger{})
+
+			app := util.PomProperties{GroupId: "com.example", ArtifactId: "demo", Version: "1.0"}
+			if err := launch.WriteAppEnv(layersDir, app); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, expected := range map[string]string{"APP_VERSION": "1.0", "APP_ARTIFACT_ID": "demo"} {
+				value, err := ioutil.ReadFile(filepath.Join(root, "app", "env.launch", name+".override"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(value) != expected {
+					t.Fatalf(`Did not set %s: got %s, want %s`, name, value, expected)
+				}
+			}
+
+			if err := launch.WriteAppEnv(layersDir, util.PomProperties{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(root, "app.toml")); !os.IsNotExist(err) {
+				t.Fatal("Did not remove the layer without coordinates")
+			}
+		})
+	})
+
+	when("#Write", func() {
+		it("should write the processes, labels and slices to launch.toml", func() {
+			root, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+
+			metadata := launch.Metadata{
+				Processes: launch.Merge(nil, detected),
+				Labels:    []launch.Label{{Key: "io.heroku.java.app.version", Value: "1.0"}},
+				Slices:    []launch.Slice{{Paths: []string{"target/classes"}}},
+			}
+			if err := launch.Write(layers.NewLayers(root, logger.Logger{}), metadata); err != nil {
+				t.Fatal(err)
+			}
+
+			var written struct {
+				Processes []map[string]interface{} `toml:"processes"`
+				Labels    []launch.Label           `toml:"labels"`
+				Slices    []launch.Slice           `toml:"slices"`
+			}
+			if _, err := toml.DecodeFile(filepath.Join(root, "launch.toml"), &written); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(written.Processes) != 2 || written.Processes[0]["type"] != "web" {
+				t.Fatalf(`Did not write the processes: %v`, written.Processes)
+			}
+			if _, ok := written.Processes[0]["default"]; ok {
+				t.Fatalf(`Did not leave out the default key: %v`, written.Processes[0])
+			}
+			if _, ok := written.Processes[0]["Source"]; ok {
+				t.Fatalf(`Did not leave out the source: %v`, written.Processes[0])
+			}
+			if len(written.Labels) != 1 || written.Labels[0].Value != "1.0" {
+				t.Fatalf(`Did not write the labels: %v`, written.Labels)
+			}
+			if len(written.Slices) != 1 || written.Slices[0].Paths[0] != "target/classes" {
+				t.Fatalf(`Did not write the slices: %v`, written.Slices)
+			}
+		})
+	})
+}
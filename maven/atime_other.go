@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package maven
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime can't be read portably, so on other platforms artifacts are only marked as used when they were
+// downloaded or are listed as dependencies.
+func accessTime(info os.FileInfo) time.Time {
+	return time.Unix(0, 0)
+}
@@ -0,0 +1,347 @@
+package maven
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	m2CacheLayerName = "maven_m2"
+
+	defaultMaxUnusedBuilds = 5
+)
+
+var (
+	// timestamped SNAPSHOT files, e.g. my-lib-1.0-20190312.101203-4.jar
+	snapshotTimestampPattern = regexp.MustCompile(`-(\d{8}\.\d{6})-(\d+)`)
+	sizePattern              = regexp.MustCompile(`^(\d+)\s*([kmgt]?)i?b?$`)
+)
+
+// cacheManifest is stored in the metadata of the maven_m2 layer. It records the last build that used each artifact,
+// identified by its version directory relative to the local repository (e.g. org/slf4j/slf4j-api/1.7.25).
+type cacheManifest struct {
+	Builds    int            `toml:"builds"`
+	Artifacts map[string]int `toml:"artifacts"`
+}
+
+// m2Cache keeps the local repository from growing forever. Before the build, it resets the access time of every
+// artifact, so that the kernel updates it when Maven reads the file even with relatime. After the build, the
+// artifacts that were read, downloaded or listed as dependencies are marked as used, and the ones that haven't been
+// used for a while are evicted. On filesystems mounted with noatime, plugins that were only read from the cache look
+// unused, and are downloaded again once they are evicted.
+type m2Cache struct {
+	layer      layers.Layer
+	repository string
+	manifest   cacheManifest
+	existing   map[string]bool
+	sizeBefore int64
+}
+
+func openM2Cache(layer layers.Layer) *m2Cache {
+	cache := &m2Cache{
+		layer:      layer,
+		repository: filepath.Join(layer.Root, "repository"),
+		existing:   map[string]bool{},
+	}
+
+	if err := layer.ReadMetadata(&cache.manifest); err != nil {
+		// an unreadable manifest only means that every artifact starts over as used in this build
+		cache.manifest = cacheManifest{}
+	}
+	if cache.manifest.Artifacts == nil {
+		cache.manifest.Artifacts = map[string]int{}
+	}
+	return cache
+}
+
+// prepare removes debris left by previous builds and resets the access times of the artifacts.
+func (c *m2Cache) prepare() error {
+	if err := c.removeDebris(); err != nil {
+		return err
+	}
+
+	epoch := time.Unix(0, 0)
+	return c.walkArtifacts(func(artifact string, files []os.FileInfo, dir string) error {
+		c.existing[artifact] = true
+		for _, file := range files {
+			c.sizeBefore += file.Size()
+			if err := os.Chtimes(filepath.Join(dir, file.Name()), epoch, file.ModTime()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeDebris deletes the markers of failed downloads, the record of which remote repository each artifact came
+// from, and all but the latest timestamped version of each SNAPSHOT file.
+func (c *m2Cache) removeDebris() error {
+	if _, err := os.Stat(c.repository); os.IsNotExist(err) {
+		return nil
+	}
+
+	var snapshots []string
+	err := filepath.Walk(c.repository, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.HasSuffix(info.Name(), "-SNAPSHOT") {
+				snapshots = append(snapshots, path)
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".lastUpdated") || info.Name() == "_remote.repositories" {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if err := removeStaleSnapshots(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeStaleSnapshots(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return err
+	}
+
+	latest := ""
+	for _, file := range files {
+		if m := snapshotTimestampPattern.FindStringSubmatch(filepath.Base(file)); m != nil && snapshotOrder(m) > latest {
+			latest = snapshotOrder(m)
+		}
+	}
+	for _, file := range files {
+		if m := snapshotTimestampPattern.FindStringSubmatch(filepath.Base(file)); m != nil && snapshotOrder(m) != latest {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotOrder sorts timestamped snapshots by timestamp, then by build number.
+func snapshotOrder(m []string) string {
+	return fmt.Sprintf("%s-%010s", m[1], m[2])
+}
+
+// walkArtifacts calls fn with every directory of the repository that holds files, which is the version directory of an
+// artifact.
+func (c *m2Cache) walkArtifacts(fn func(artifact string, files []os.FileInfo, dir string) error) error {
+	if _, err := os.Stat(c.repository); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(c.repository, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || path == c.repository {
+			return err
+		}
+
+		dir, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		entries, err := dir.Readdir(-1)
+		dir.Close()
+		if err != nil {
+			return err
+		}
+
+		var files []os.FileInfo
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), "maven-metadata") {
+				files = append(files, entry)
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		artifact, err := filepath.Rel(c.repository, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(artifact), files, path)
+	})
+}
+
+type cachedArtifact struct {
+	Path     string
+	LastUsed int
+	Size     int64
+}
+
+// prune marks the artifacts used by this build, evicts the ones that are unused or over the size limit, and saves the
+// manifest.
+func (c *m2Cache) prune(dependencies []Dependency) (int64, int64, int, error) {
+	maxUnused, maxSize, err := cacheLimits()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	c.manifest.Builds++
+	build := c.manifest.Builds
+
+	used := map[string]bool{}
+	for _, dependency := range dependencies {
+		used[dependencyPath(dependency)] = true
+	}
+
+	var artifacts []cachedArtifact
+	err = c.walkArtifacts(func(artifact string, files []os.FileInfo, dir string) error {
+		var size int64
+		read := false
+		for _, file := range files {
+			size += file.Size()
+			if accessTime(file).After(time.Unix(0, 0)) {
+				read = true
+			}
+		}
+
+		_, known := c.manifest.Artifacts[artifact]
+		if read || used[artifact] || !c.existing[artifact] || !known {
+			c.manifest.Artifacts[artifact] = build
+		}
+		artifacts = append(artifacts, cachedArtifact{Path: artifact, LastUsed: c.manifest.Artifacts[artifact], Size: size})
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// the least recently used artifacts are evicted first when the cache is too large
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].LastUsed < artifacts[j].LastUsed
+	})
+
+	var size int64
+	for _, artifact := range artifacts {
+		size += artifact.Size
+	}
+
+	evicted := 0
+	remaining := map[string]int{}
+	for _, artifact := range artifacts {
+		unused := build-artifact.LastUsed >= maxUnused
+		tooLarge := maxSize > 0 && size > maxSize
+		if unused || tooLarge {
+			if err := c.evict(artifact.Path); err != nil {
+				return 0, 0, 0, err
+			}
+			size -= artifact.Size
+			evicted++
+			continue
+		}
+		remaining[artifact.Path] = artifact.LastUsed
+	}
+	c.manifest.Artifacts = remaining
+
+	return c.sizeBefore, size, evicted, c.layer.WriteMetadata(c.manifest, layers.Cache)
+}
+
+// evict removes the files of an artifact version, and the directories that are left empty.
+func (c *m2Cache) evict(artifact string) error {
+	dir := filepath.Join(c.repository, filepath.FromSlash(artifact))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	for parent := filepath.Dir(dir); parent != c.repository && strings.HasPrefix(parent, c.repository); parent = filepath.Dir(parent) {
+		entries, err := filepath.Glob(filepath.Join(parent, "*"))
+		if err != nil || len(entries) > 0 {
+			// the artifact's maven-metadata files are kept along with its other versions
+			break
+		}
+		if err := os.Remove(parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencyPath is the directory of a dependency in the local repository.
+func dependencyPath(dependency Dependency) string {
+	return strings.Join([]string{
+		strings.Replace(dependency.GroupId, ".", "/", -1),
+		dependency.ArtifactId,
+		dependency.Version,
+	}, "/")
+}
+
+// cacheLimits reads MAVEN_CACHE_MAX_UNUSED_BUILDS, the number of builds an artifact is kept for without being used,
+// and MAVEN_CACHE_MAX_SIZE, the size of the cache (e.g. 2G or 500M) above which the least recently used artifacts are
+// evicted.
+func cacheLimits() (int, int64, error) {
+	maxUnused := defaultMaxUnusedBuilds
+	if value, isSet := os.LookupEnv("MAVEN_CACHE_MAX_UNUSED_BUILDS"); isSet {
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || parsed < 1 {
+			return 0, 0, invalidCacheLimit("MAVEN_CACHE_MAX_UNUSED_BUILDS", value)
+		}
+		maxUnused = parsed
+	}
+
+	var maxSize int64
+	if value, isSet := os.LookupEnv("MAVEN_CACHE_MAX_SIZE"); isSet {
+		parsed, ok := parseSize(value)
+		if !ok {
+			return 0, 0, invalidCacheLimit("MAVEN_CACHE_MAX_SIZE", value)
+		}
+		maxSize = parsed
+	}
+
+	return maxUnused, maxSize, nil
+}
+
+func parseSize(value string) (int64, bool) {
+	m := sizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if m == nil {
+		return 0, false
+	}
+
+	size, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	for _, unit := range "kmgt" {
+		if m[2] == "" {
+			break
+		}
+		size *= 1024
+		if m[2] == string(unit) {
+			break
+		}
+	}
+	return size, true
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGT"[exp])
+}
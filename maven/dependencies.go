@@ -0,0 +1,139 @@
+package maven
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dependencyListFile is where dependency:list writes its output, relative to each module. It's set with
+// -DoutputFile when Maven is run.
+const dependencyListFile = "target/dependencies.txt"
+
+// Dependency is a resolved artifact, as reported by dependency:list.
+type Dependency struct {
+	GroupId    string `toml:"group_id"`
+	ArtifactId string `toml:"artifact_id"`
+	Type       string `toml:"type"`
+	Classifier string `toml:"classifier,omitempty"`
+	Version    string `toml:"version"`
+	Scope      string `toml:"scope"`
+	Optional   bool   `toml:"optional,omitempty"`
+}
+
+// Coordinates formats the dependency the way Maven does, e.g. org.postgresql:postgresql:jar:42.2.5.
+func (d Dependency) Coordinates() string {
+	parts := []string{d.GroupId, d.ArtifactId, d.Type}
+	if d.Classifier != "" {
+		parts = append(parts, d.Classifier)
+	}
+	return strings.Join(append(parts, d.Version), ":")
+}
+
+// IsRuntime is true for the dependencies that end up on the app's classpath when it runs.
+func (d Dependency) IsRuntime() bool {
+	switch d.Scope {
+	case "compile", "runtime", "system":
+		return true
+	}
+	return false
+}
+
+// ReadDependencyList reads a file written by dependency:list.
+func ReadDependencyList(filename string) ([]Dependency, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dependencies, err := parseDependencyList(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", filename, err)
+	}
+	return dependencies, nil
+}
+
+// parseDependencyList reads lines in the form groupId:artifactId:type[:classifier]:version:scope. Newer versions of
+// the plugin follow them with "(optional)" and the Java module name, which are ignored apart from the optional flag.
+func parseDependencyList(r io.Reader) ([]Dependency, error) {
+	var dependencies []Dependency
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text == "none" || strings.HasPrefix(text, "The following files have been resolved") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		dependency, ok := parseDependency(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid dependency %q", line, fields[0])
+		}
+		for _, field := range fields[1:] {
+			if field == "(optional)" {
+				dependency.Optional = true
+			}
+		}
+		dependencies = append(dependencies, dependency)
+	}
+
+	return dependencies, scanner.Err()
+}
+
+func parseDependency(coordinates string) (Dependency, bool) {
+	parts := strings.Split(coordinates, ":")
+	for _, part := range parts {
+		if part == "" {
+			return Dependency{}, false
+		}
+	}
+
+	switch len(parts) {
+	case 5:
+		return Dependency{GroupId: parts[0], ArtifactId: parts[1], Type: parts[2], Version: parts[3], Scope: parts[4]}, true
+	case 6:
+		return Dependency{GroupId: parts[0], ArtifactId: parts[1], Type: parts[2], Classifier: parts[3], Version: parts[4], Scope: parts[5]}, true
+	}
+	return Dependency{}, false
+}
+
+// readModuleDependencies reads the dependencies of a module. Modules that weren't built, e.g. because they were not
+// selected with MAVEN_PROJECTS, don't have a dependency list.
+func readModuleDependencies(appDir string, module Module) ([]Dependency, error) {
+	dependencies, err := ReadDependencyList(filepath.Join(appDir, module.Path, dependencyListFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return dependencies, err
+}
+
+// Dependencies lists the dependencies of every module, without duplicates. When modules depend on the same artifact
+// with different scopes, the first one is kept.
+func (m BuildMetadata) Dependencies() []Dependency {
+	var dependencies []Dependency
+	seen := map[string]bool{}
+	for _, module := range m.Modules {
+		for _, dependency := range module.Dependencies {
+			if !seen[dependency.Coordinates()] {
+				seen[dependency.Coordinates()] = true
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies
+}
+
+// FindDependency looks for a dependency of any module, e.g. to check whether the app uses a given JDBC driver.
+func (m BuildMetadata) FindDependency(groupId, artifactId string) (Dependency, bool) {
+	for _, dependency := range m.Dependencies() {
+		if dependency.GroupId == groupId && dependency.ArtifactId == artifactId {
+			return dependency, true
+		}
+	}
+	return Dependency{}, false
+}
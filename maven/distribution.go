@@ -0,0 +1,45 @@
+package maven
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+)
+
+// Distribution is the version of Maven that ran the build, and where it was downloaded from.
+type Distribution struct {
+	Version string `toml:"version"`
+	Url     string `toml:"url"`
+}
+
+var distributionVersionPattern = regexp.MustCompile(`apache-maven-([^/]+)-bin\.(?:zip|tar\.gz)$`)
+
+// FindDistribution reads the distribution from the Maven wrapper when the app has one, and otherwise from the
+// metadata that maven-installer writes for the maven layer. It returns an empty Distribution when neither is found.
+func FindDistribution(appDir string, layersDir layers.Layers) (Distribution, error) {
+	var r Runner
+	if r.hasMavenWrapper(appDir) {
+		properties, err := util.ReadPropertiesFile(filepath.Join(appDir, ".mvn", "wrapper", "maven-wrapper.properties"))
+		if err != nil {
+			return Distribution{}, err
+		}
+
+		distribution := Distribution{Url: strings.TrimSpace(properties["distributionUrl"])}
+		if m := distributionVersionPattern.FindStringSubmatch(distribution.Url); m != nil {
+			distribution.Version = m[1]
+		}
+		return distribution, nil
+	}
+
+	var distribution Distribution
+	mavenLayer := layersDir.Layer("maven")
+	if _, err := os.Stat(mavenLayer.Metadata); os.IsNotExist(err) {
+		return distribution, nil
+	}
+	err := mavenLayer.ReadMetadata(&distribution)
+	return distribution, err
+}
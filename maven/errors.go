@@ -23,6 +23,18 @@ func failedToRunMaven(cause error) error {
 	return errorWithCause("Failed to build app with Maven", cause)
 }
 
+func failedToBuildWithMaven(kind failureKind, details []string, cause error) error {
+	message := fmt.Sprintf("Failed to build app with Maven: %s", kind.Name)
+	if len(details) > maxFailureDetails {
+		details = append(details[:maxFailureDetails], fmt.Sprintf("... and %d more", len(details)-maxFailureDetails))
+	}
+	for _, detail := range details {
+		message += "\n  " + detail
+	}
+	message += "\n\n" + kind.Suggestion
+	return errorWithCause(message, cause)
+}
+
 func failedToDownloadSettings(cause error) error {
 	return errorWithCause(fmt.Sprintf("Failed to download settings.xml from URL"), cause)
 }
@@ -30,3 +42,36 @@ func failedToDownloadSettings(cause error) error {
 func failedToDownloadSettingsFromUrl(url string, cause error) error {
 	return errorWithCause(fmt.Sprintf("Failed to download settings.xml from URL: %s", url), cause)
 }
+
+func failedToGenerateSettings(cause error) error {
+	return errorWithCause("Failed to generate settings.xml from environment variables", cause)
+}
+
+func failedToWriteGeneratedFile(path string, cause error) error {
+	return errorWithCause(fmt.Sprintf("Failed to write %s", path), cause)
+}
+
+func invalidSettingsChecksum(expected, actual string) error {
+	return errorWithCause("Downloaded settings.xml does not match MAVEN_SETTINGS_SHA256", errors.New(fmt.Sprintf("expected %s, got %s", expected, actual)))
+}
+
+func invalidSettingsXml(url string, cause error) error {
+	return errorWithCause(fmt.Sprintf("Downloaded settings.xml from URL is not valid: %s", url), cause)
+}
+
+func failedToGenerateToolchains(cause error) error {
+	return errorWithCause("Failed to generate toolchains.xml for the installed JDKs", cause)
+}
+
+func invalidCacheLimit(name, value string) error {
+	return errorWithCause(fmt.Sprintf("Invalid value for %s: %q", name, value),
+		errors.New("expected a positive number of builds, or a size such as 500M or 2G"))
+}
+
+func failedToSeedRepository(repository string, cause error) error {
+	return errorWithCause(fmt.Sprintf("Failed to copy the artifacts from %s into the Maven cache", repository), cause)
+}
+
+func failedToReadSelectedModules(cause error) error {
+	return errorWithCause("Failed to read the Maven modules, so the ones selected by MAVEN_PROJECTS can't be found", cause)
+}
@@ -0,0 +1,14 @@
+package maven
+
+import "time"
+
+// SetSettingsDownloadBackoff shortens the wait between download attempts, and returns a func that restores it.
+func SetSettingsDownloadBackoff(backoff time.Duration) func() {
+	previous := settingsDownloadBackoff
+	settingsDownloadBackoff = backoff
+	return func() { settingsDownloadBackoff = previous }
+}
+
+func (r *Runner) WriteGeneratedFiles() error {
+	return r.writeGeneratedFiles()
+}
@@ -0,0 +1,287 @@
+package maven
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+)
+
+const targetCacheLayerName = "maven_target"
+
+// targetCacheMetadata records the hash of the sources each cached target directory was built from, by module path.
+type targetCacheMetadata struct {
+	Modules map[string]string `toml:"modules"`
+}
+
+// targetCache keeps the target directory of each module between builds. A module's target directory is only restored
+// when none of its files, the poms it inherits from, or the reactor modules it depends on have changed, and Maven's own
+// staleness checks take care of the rest.
+type targetCache struct {
+	layer    layers.Layer
+	metadata targetCacheMetadata
+	modules  []Module
+	hashes   map[string]string
+}
+
+// incrementalEnabled is true when MAVEN_INCREMENTAL opts in to reusing the target directories of unchanged modules.
+func incrementalEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MAVEN_INCREMENTAL"))
+	return enabled
+}
+
+// withoutClean removes the clean goal, which would delete the restored target directories.
+func withoutClean(goals []string) []string {
+	var filtered []string
+	for _, goal := range goals {
+		if goal != "clean" && goal != "clean:clean" {
+			filtered = append(filtered, goal)
+		}
+	}
+	return filtered
+}
+
+// restoreTargets copies the cached target directory of each unchanged module back into the app.
+func (r *Runner) restoreTargets(appDir string, layersDir layers.Layers) (*targetCache, error) {
+	modules, err := r.readModules(appDir)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := hashModules(appDir, modules)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &targetCache{
+		layer:   layersDir.Layer(targetCacheLayerName),
+		modules: modules,
+		hashes:  hashes,
+	}
+	if err := cache.layer.ReadMetadata(&cache.metadata); err != nil {
+		return nil, err
+	}
+	if cache.metadata.Modules == nil {
+		cache.metadata.Modules = map[string]string{}
+	}
+
+	var restored, rebuilt []string
+	for _, module := range modules {
+		hash := hashes[module.Path]
+		cached := cache.targetDir(module)
+		target := filepath.Join(appDir, module.Path, "target")
+		if _, err := os.Stat(target); err == nil {
+			// the app was pushed with its target directory, which takes precedence
+			continue
+		}
+		if _, err := os.Stat(cached); err != nil || cache.metadata.Modules[module.Path] != hash {
+			rebuilt = append(rebuilt, module.Path)
+			continue
+		}
+
+		if err := copyDir(cached, target); err != nil {
+			return nil, err
+		}
+		restored = append(restored, module.Path)
+	}
+
+	fmt.Fprintf(r.Out, "Incremental build: reusing target/ of %d unchanged modules", len(restored))
+	if len(rebuilt) > 0 {
+		fmt.Fprintf(r.Out, ", rebuilding %s", strings.Join(rebuilt, ", "))
+	}
+	fmt.Fprintln(r.Out)
+	return cache, nil
+}
+
+// save replaces the cached target directories with the ones from this build.
+func (c *targetCache) save(appDir string) error {
+	if err := os.RemoveAll(c.layer.Root); err != nil {
+		return err
+	}
+
+	metadata := targetCacheMetadata{Modules: map[string]string{}}
+	for _, module := range c.modules {
+		target := filepath.Join(appDir, module.Path, "target")
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyDir(target, c.targetDir(module)); err != nil {
+			return err
+		}
+		metadata.Modules[module.Path] = c.hashes[module.Path]
+	}
+
+	return c.layer.WriteMetadata(metadata, layers.Cache)
+}
+
+func (c *targetCache) targetDir(module Module) string {
+	return filepath.Join(c.layer.Root, "modules", module.Path, "target")
+}
+
+// hashModules hashes each module along with the hashes of the reactor modules it depends on, directly or not, since
+// a change to a dependency can break a module that wasn't touched, e.g. when a method it calls is removed.
+func hashModules(appDir string, modules []Module) (map[string]string, error) {
+	own := map[string]string{}
+	byCoordinates := map[util.PomDependency]Module{}
+	for _, module := range modules {
+		hash, err := hashModule(appDir, module, modules)
+		if err != nil {
+			return nil, err
+		}
+		own[module.Path] = hash
+		byCoordinates[util.PomDependency{GroupId: module.GroupId, ArtifactId: module.ArtifactId}] = module
+	}
+
+	hashes := map[string]string{}
+	var combine func(module Module, visiting map[string]bool) string
+	combine = func(module Module, visiting map[string]bool) string {
+		if hash, ok := hashes[module.Path]; ok {
+			return hash
+		}
+		// Maven rejects cycles in the reactor, so this only guards against looping on a broken pom
+		visiting[module.Path] = true
+		defer delete(visiting, module.Path)
+
+		var dependencies []string
+		for _, declared := range module.declared {
+			if dependency, ok := byCoordinates[declared]; ok && !visiting[dependency.Path] {
+				dependencies = append(dependencies, dependency.Path+"\x00"+combine(dependency, visiting))
+			}
+		}
+		sort.Strings(dependencies)
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s\x00%s", own[module.Path], strings.Join(dependencies, "\x00"))
+		hashes[module.Path] = fmt.Sprintf("%x", hash.Sum(nil))
+		return hashes[module.Path]
+	}
+
+	for _, module := range modules {
+		combine(module, map[string]bool{})
+	}
+	return hashes, nil
+}
+
+// hashModule hashes the files of a module, leaving out its target directory and the directories of its own modules,
+// along with the poms of the modules it's nested in, which it usually inherits from.
+func hashModule(appDir string, module Module, modules []Module) (string, error) {
+	moduleDir := filepath.Join(appDir, module.Path)
+
+	excluded := map[string]bool{filepath.Join(moduleDir, "target"): true}
+	var parents []string
+	for _, other := range modules {
+		switch {
+		case other.Path == module.Path:
+		case isParentDir(module.Path, other.Path):
+			excluded[filepath.Join(appDir, other.Path)] = true
+		case isParentDir(other.Path, module.Path):
+			parents = append(parents, filepath.Join(appDir, other.Path, "pom.xml"))
+		}
+	}
+
+	hash := sha256.New()
+	var files []string
+	err := filepath.Walk(moduleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (excluded[path] || info.Name() == ".git") {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(parents)
+	for _, file := range append(files, parents...) {
+		rel, err := filepath.Rel(appDir, file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00", rel)
+
+		if err := hashFile(hash, file); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// isParentDir is true when child is a module nested in parent. Both are module paths relative to the app.
+func isParentDir(parent, child string) bool {
+	if parent == "." {
+		return child != "."
+	}
+	return strings.HasPrefix(child, parent+string(filepath.Separator))
+}
+
+// copyDir copies a directory recursively, keeping the modification times so that Maven doesn't consider the copied
+// classes to be older than their sources.
+func copyDir(src, dest string) error {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info.Mode()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// directories are written to while their contents are copied, so their times are set afterwards
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(filepath.Join(dest, rel), info.ModTime(), info.ModTime())
+	})
+}
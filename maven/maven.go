@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/buildpack/libbuildpack/layers"
 )
@@ -20,8 +21,19 @@ type Runner struct {
 	Command  string
 	Options  []string
 	Goals    []string
+	// generated are the files that Init points Maven at, which are only written by Run
+	generated []generatedFile
 }
 
+// generatedFile is written into a directory of the layers dir without layer metadata, so that it's never cached or
+// exported, and removed once Maven is done since it can hold passwords.
+type generatedFile struct {
+	Path    string
+	Content []byte
+}
+
+const generatedFilesDir = "maven_generated"
+
 func (r *Runner) Run(appDir, defaultGoals string, options []string, layersDir layers.Layers) error {
 	r.Goals = parseGoals(defaultGoals)
 	r.Options = trimArgs(options)
@@ -31,12 +43,35 @@ func (r *Runner) Run(appDir, defaultGoals string, options []string, layersDir la
 		return err
 	}
 
-	m2Dir, err := r.createMavenRepoDir(appDir, layersDir)
+	if err := r.writeGeneratedFiles(); err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Join(layersDir.Root, generatedFilesDir))
+
+	m2Dir, cache, err := r.createMavenRepoDir(appDir, layersDir)
 	if err != nil {
 		return err
 	}
 	defer r.removeMavenRepoSymlink(m2Dir)
 
+	if seeded := seededRepository(appDir); seeded != "" {
+		fmt.Fprintf(r.Out, "Building offline with the repository in %s\n", seeded)
+		if err := mergeMavenRepo(seeded, cache.repository); err != nil {
+			return failedToSeedRepository(seeded, err)
+		}
+	}
+
+	if err := cache.prepare(); err != nil {
+		fmt.Fprintf(r.Err, "Failed to clean up the Maven cache: %s\n", err)
+	}
+
+	var targets *targetCache
+	if incrementalEnabled() {
+		if targets, err = r.restoreTargets(appDir, layersDir); err != nil {
+			fmt.Fprintf(r.Err, "Failed to restore the cached target directories: %s\n", err)
+		}
+	}
+
 	mavenArgs := append(r.Options, r.Goals...)
 
 	fmt.Printf("$ mvn %s %s\n", strings.Join(r.Options, " "), strings.Join(r.Goals, " "))
@@ -44,13 +79,42 @@ func (r *Runner) Run(appDir, defaultGoals string, options []string, layersDir la
 	cmd.Env = os.Environ()
 	cmd.Dir = appDir
 	cmd.Stdin = bytes.NewBuffer(r.In)
-	cmd.Stdout = r.Out
-	cmd.Stderr = r.Err
+	output := newOutputParser(appDir)
+	stdout, stderr := output.Writer(), output.Writer()
+	cmd.Stdout = io.MultiWriter(r.Out, stdout)
+	cmd.Stderr = io.MultiWriter(r.Err, stderr)
+
+	started := time.Now()
+	err = cmd.Run()
+	stdout.Close()
+	stderr.Close()
+
+	if runTestsEnabled() {
+		if reportErr := r.reportTests(appDir, layersDir, started); reportErr != nil {
+			fmt.Fprintf(r.Err, "Failed to collect test reports: %s\n", reportErr)
+		}
+	} else if removeErr := removeTestReports(layersDir); removeErr != nil {
+		return removeErr
+	}
 
-	if err := cmd.Run(); err != nil {
+	if err != nil {
+		if kind, details, found := output.Failure(); found {
+			return failedToBuildWithMaven(kind, details, err)
+		}
 		return failedToRunMaven(err)
 	}
 
+	if err := r.recordArtifacts(appDir, layersDir); err != nil {
+		return err
+	}
+
+	r.pruneCache(cache, layersDir)
+
+	if targets != nil {
+		if err := targets.save(appDir); err != nil {
+			fmt.Fprintf(r.Err, "Failed to cache the target directories: %s\n", err)
+		}
+	}
 	return nil
 }
 
@@ -62,13 +126,24 @@ func (r *Runner) Init(appDir string, layersDir layers.Layers) error {
 	}
 
 	r.Command = mvn
-	r.Options, err = r.constructOptions(appDir)
+	r.generated = nil
+	r.Options, err = r.constructOptions(appDir, layersDir)
 	if err != nil {
 		return err
 	}
 
+	toolchainsOpts, err := r.constructToolchainsOpts(layersDir)
+	if err != nil {
+		return err
+	}
+	r.Options = append(r.Options, toolchainsOpts...)
+
 	r.Goals = r.constructGoals(r.Goals)
 
+	if _, _, err := cacheLimits(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -97,21 +172,37 @@ func (r *Runner) installMaven(installDir string) (string, error) {
 }
 
 func (r *Runner) constructGoals(defaultGoals []string) []string {
-	if goals, isSet := os.LookupEnv("MAVEN_CUSTOM_GOALS"); isSet {
-		return parseGoals(goals)
+	goals := defaultGoals
+	if customGoals, isSet := os.LookupEnv("MAVEN_CUSTOM_GOALS"); isSet {
+		goals = parseGoals(customGoals)
 	}
-	return defaultGoals
+	if incrementalEnabled() {
+		return withoutClean(goals)
+	}
+	return goals
 }
 
-func (r *Runner) constructOptions(appDir string) ([]string, error) {
+func (r *Runner) constructOptions(appDir string, layersDir layers.Layers) ([]string, error) {
 	opts := []string{
 		"-B",
-		"-DoutputFile=target/dependencies.txt",
+		"-DoutputFile=" + dependencyListFile,
+	}
+
+	if runTestsEnabled() {
+		opts = append(opts, withoutSkipTests(r.Options)...)
+	} else {
+		opts = append(opts, r.Options...)
+	}
+
+	if seededRepository(appDir) != "" {
+		opts = append(opts, "-o")
 	}
 
-	opts = append(opts, r.Options...)
+	if projects := selectedProjects(); len(projects) > 0 {
+		opts = append(opts, "-pl", strings.Join(projects, ","), "-am")
+	}
 
-	settingsOpt, err := r.constructSettingsOpts(appDir)
+	settingsOpt, err := r.constructSettingsOpts(appDir, layersDir)
 	if err != nil {
 		return []string{}, err
 	}
@@ -125,58 +216,204 @@ func (r *Runner) constructOptions(appDir string) ([]string, error) {
 	return trimArgs(opts), nil
 }
 
-func (r *Runner) constructSettingsOpts(appDir string) ([]string, error) {
-	if mvnSettingsPath, isSet := os.LookupEnv("MAVEN_SETTINGS_PATH"); isSet {
-		return []string{"-s", mvnSettingsPath}, nil
-	} else if mvnSettingsUrl, isSet := os.LookupEnv("MAVEN_SETTINGS_URL"); isSet {
-		settingsXml := filepath.Join(os.TempDir(), "settings.xml")
+// constructSettingsOpts uses the settings.xml provided by the app, if any. When the environment also defines
+// repositories or mirrors, the app's file is passed as the global settings so that Maven merges the two, with the
+// environment taking precedence for servers, mirrors and profiles that share an id. Proxies alone don't override the
+// app's settings, so they are passed as the global settings instead.
+func (r *Runner) constructSettingsOpts(appDir string, layersDir layers.Layers) ([]string, error) {
+	appSettings, err := r.appSettingsPath(appDir, layersDir)
+	if err != nil {
+		return nil, err
+	}
 
-		out, err := os.Create(settingsXml)
-		if err != nil {
-			return nil, failedToDownloadSettings(err)
+	envSettings := settingsFromEnv(os.Environ())
+	if envSettings.isEmpty() {
+		if appSettings == "" {
+			return nil, nil
 		}
-		defer out.Close()
+		return []string{"-s", appSettings}, nil
+	}
 
-		resp, err := http.Get(mvnSettingsUrl)
-		if err != nil {
-			return nil, failedToDownloadSettings(err)
-		}
-		defer resp.Body.Close()
+	content, err := marshalSettings(envSettings)
+	if err != nil {
+		return nil, failedToGenerateSettings(err)
+	}
+	envSettingsXml := r.generate(layersDir, "settings.xml", content)
+
+	switch {
+	case appSettings == "":
+		return []string{"-s", envSettingsXml}, nil
+	case envSettings.hasRepositories():
+		return []string{"-gs", appSettings, "-s", envSettingsXml}, nil
+	default:
+		return []string{"-s", appSettings, "-gs", envSettingsXml}, nil
+	}
+}
 
-		_, err = io.Copy(out, resp.Body)
-		if err != nil {
-			return nil, failedToDownloadSettings(err)
+// generate returns the path of a file that Run writes before starting Maven.
+func (r *Runner) generate(layersDir layers.Layers, name string, content []byte) string {
+	path := filepath.Join(layersDir.Root, generatedFilesDir, name)
+	r.generated = append(r.generated, generatedFile{Path: path, Content: content})
+	return path
+}
+
+// writeGeneratedFiles writes the files generated by Init, which are only readable by the current user.
+func (r *Runner) writeGeneratedFiles() error {
+	for _, file := range r.generated {
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0700); err != nil {
+			return failedToWriteGeneratedFile(file.Path, err)
+		}
+		if err := ioutil.WriteFile(file.Path, file.Content, 0600); err != nil {
+			return failedToWriteGeneratedFile(file.Path, err)
 		}
+	}
+	return nil
+}
 
-		if _, err := os.Stat(settingsXml); os.IsNotExist(err) {
-			return nil, failedToDownloadSettingsFromUrl(mvnSettingsUrl, err)
+func (r *Runner) appSettingsPath(appDir string, layersDir layers.Layers) (string, error) {
+	if mvnSettingsPath, isSet := os.LookupEnv("MAVEN_SETTINGS_PATH"); isSet {
+		return mvnSettingsPath, nil
+	} else if mvnSettingsUrl, isSet := os.LookupEnv("MAVEN_SETTINGS_URL"); isSet {
+		content, err := downloadSettings(mvnSettingsUrl)
+		if err != nil {
+			return "", err
 		}
-		return []string{"-s", settingsXml}, nil
+		return r.generate(layersDir, "downloaded-settings.xml", content), nil
 	} else if _, err := os.Stat(filepath.Join(appDir, "settings.xml")); !os.IsNotExist(err) {
-		return []string{"-s", "settings.xml"}, nil
+		return "settings.xml", nil
 	}
-	return nil, nil
+	return "", nil
 }
 
-func (r *Runner) createMavenRepoDir(appDir string, layersDir layers.Layers) (string, error) {
+// createMavenRepoDir links ~/.m2 to the maven_m2 cache layer, so that the local repository and the distributions
+// downloaded by the Maven wrapper are cached. If ~/.m2 already exists (e.g. it was provided by the stack image), it's
+// left as it is: its repository is merged into the layer, and Maven is pointed at the layer with -Dmaven.repo.local.
+// It returns the symlink to remove once the build is done, which is empty when none was created.
+func (r *Runner) createMavenRepoDir(appDir string, layersDir layers.Layers) (string, *m2Cache, error) {
 	m2Dir, err := defaultMavenHome()
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error getting maven home: %s", err))
+		return "", nil, errors.New(fmt.Sprintf("error getting maven home: %s", err))
 	}
 
-	m2CacheLayer := layersDir.Layer("maven_m2")
+	m2CacheLayer := layersDir.Layer(m2CacheLayerName)
+	cache := openM2Cache(m2CacheLayer)
 
-	m2CacheLayer.WriteMetadata(nil, layers.Cache)
+	if err := m2CacheLayer.WriteMetadata(cache.manifest, layers.Cache); err != nil {
+		return "", nil, errors.New(fmt.Sprintf("error writing maven cache layer metadata: %s", err))
+	}
 
 	err = os.MkdirAll(m2CacheLayer.Root, os.ModePerm)
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error creating maven cache layer: %s", err))
+		return "", nil, errors.New(fmt.Sprintf("error creating maven cache layer: %s", err))
+	}
+
+	info, err := os.Lstat(m2Dir)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return "", nil, errors.New(fmt.Sprintf("error reading maven home: %s", err))
+	case r.isMavenRepoSymlink(m2Dir, info, m2CacheLayer.Root):
+		// left behind by a build that was killed before it could clean up
+		if err := os.Remove(m2Dir); err != nil {
+			return "", nil, errors.New(fmt.Sprintf("error removing stale maven home link: %s", err))
+		}
+	default:
+		fmt.Fprintf(r.Out, "Using the existing %s, with the local repository cached in a layer\n", m2Dir)
+		if err := mergeMavenRepo(filepath.Join(m2Dir, "repository"), cache.repository); err != nil {
+			return "", nil, errors.New(fmt.Sprintf("error merging %s into the maven cache layer: %s", m2Dir, err))
+		}
+		r.Options = append(r.Options, "-Dmaven.repo.local="+cache.repository)
+		return "", cache, nil
 	}
 
-	return m2Dir, os.Symlink(m2CacheLayer.Root, m2Dir)
+	if err := os.MkdirAll(filepath.Dir(m2Dir), os.ModePerm); err != nil {
+		return "", nil, errors.New(fmt.Sprintf("error creating maven home: %s", err))
+	}
+	if err := os.Symlink(m2CacheLayer.Root, m2Dir); err != nil {
+		return "", nil, errors.New(fmt.Sprintf("error linking maven home to the cache layer: %s", err))
+	}
+	return m2Dir, cache, nil
+}
+
+func (r *Runner) isMavenRepoSymlink(m2Dir string, info os.FileInfo, layerRoot string) bool {
+	if info.Mode()&os.ModeSymlink != os.ModeSymlink {
+		return false
+	}
+	target, err := os.Readlink(m2Dir)
+	return err == nil && filepath.Clean(target) == filepath.Clean(layerRoot)
+}
+
+// mergeMavenRepo adds the artifacts of an existing local repository to the cache layer, without replacing the ones
+// that are already cached. Files are hard linked when possible, to avoid copying them.
+func mergeMavenRepo(repository, cacheRepository string) error {
+	if _, err := os.Stat(repository); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(repository, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(path, ".lastUpdated") {
+			return err
+		}
+
+		rel, err := filepath.Rel(repository, path)
+		if err != nil || filepath.Dir(rel) == "." {
+			// artifacts are never at the top of a repository, but binding metadata is
+			return err
+		}
+		dest := filepath.Join(cacheRepository, rel)
+		if _, err := os.Stat(dest); err == nil {
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Link(path, dest); err == nil {
+			return nil
+		}
+		return copyFile(path, dest, info.Mode())
+	})
+}
+
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// pruneCache evicts the artifacts this build didn't need. A failure only means the cache is bigger than it should be,
+// so it doesn't fail the build.
+func (r *Runner) pruneCache(cache *m2Cache, layersDir layers.Layers) {
+	buildMetadata, err := ReadBuildMetadata(layersDir)
+	if err != nil {
+		fmt.Fprintf(r.Err, "Failed to read the build metadata: %s\n", err)
+	}
+
+	before, after, evicted, err := cache.prune(buildMetadata.Dependencies())
+	if err != nil {
+		fmt.Fprintf(r.Err, "Failed to prune the Maven cache: %s\n", err)
+		return
+	}
+	fmt.Fprintf(r.Out, "Maven cache: %s before the build, %s after (%d unused artifacts removed)\n",
+		formatSize(before), formatSize(after), evicted)
 }
 
 func (r *Runner) removeMavenRepoSymlink(m2Dir string) error {
+	if m2Dir == "" {
+		return nil
+	}
 	fi, err := os.Lstat(m2Dir)
 	if err != nil {
 		return err
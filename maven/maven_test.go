@@ -1,8 +1,11 @@
 package maven_test
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/buildpack/libbuildpack/layers"
 	"github.com/buildpack/libbuildpack/logger"
+	"github.com/heroku/java-buildpack/jdk"
 	"github.com/heroku/java-buildpack/maven"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -102,6 +106,251 @@ func testMaven(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("MAVEN_SETTINGS_URL is set", func() {
+			var server *httptest.Server
+
+			it.Before(func() {
+				appDir = fixture("app_with_wrapper")
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Authorization") != "Bearer t0ken" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					switch r.URL.Path {
+					case "/settings.xml":
+						fmt.Fprint(w, `<settings><servers/></settings>`)
+					case "/index.html":
+						fmt.Fprint(w, `<html><body>Not settings</body></html>`)
+					default:
+						http.NotFound(w, r)
+					}
+				}))
+				os.Setenv("MAVEN_SETTINGS_AUTH_TOKEN", "t0ken")
+			})
+
+			it("should download the settings as a generated file", func() {
+				os.Setenv("MAVEN_SETTINGS_URL", server.URL+"/settings.xml")
+
+				if err := runner.Init(appDir, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				downloaded := optionValue(runner.Options, "-s")
+				if !strings.HasPrefix(downloaded, layersDir.Root) {
+					t.Fatalf(`downloaded settings are not in the layers dir: %s`, downloaded)
+				}
+				if _, err := os.Stat(downloaded); !os.IsNotExist(err) {
+					t.Fatal("downloaded settings were written by Init")
+				}
+
+				if err := runner.WriteGeneratedFiles(); err != nil {
+					t.Fatal(err)
+				}
+				settings, err := ioutil.ReadFile(downloaded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(settings) != `<settings><servers/></settings>` {
+					t.Fatalf(`downloaded settings did not match: %s`, settings)
+				}
+			})
+
+			it("should fail when the response is not successful", func() {
+				os.Setenv("MAVEN_SETTINGS_URL", server.URL+"/missing.xml")
+
+				if err := runner.Init(appDir, layersDir); err == nil {
+					t.Fatal("unexpected success")
+				}
+			})
+
+			it("should fail when the response is not a settings file", func() {
+				os.Setenv("MAVEN_SETTINGS_URL", server.URL+"/index.html")
+
+				if err := runner.Init(appDir, layersDir); err == nil {
+					t.Fatal("unexpected success")
+				}
+			})
+
+			it("should fail when the checksum does not match", func() {
+				os.Setenv("MAVEN_SETTINGS_URL", server.URL+"/settings.xml")
+				os.Setenv("MAVEN_SETTINGS_SHA256", "0000")
+
+				err := runner.Init(appDir, layersDir)
+				if err == nil || !strings.Contains(err.Error(), "MAVEN_SETTINGS_SHA256") {
+					t.Fatalf("expected a checksum error, got %v", err)
+				}
+			})
+
+			it.After(func() {
+				server.Close()
+				os.Unsetenv("MAVEN_SETTINGS_URL")
+				os.Unsetenv("MAVEN_SETTINGS_AUTH_TOKEN")
+				os.Unsetenv("MAVEN_SETTINGS_SHA256")
+			})
+		})
+
+		when("the MAVEN_SETTINGS_URL server fails", func() {
+			var (
+				server   *httptest.Server
+				requests int
+				statuses []int
+				restore  func()
+			)
+
+			it.Before(func() {
+				appDir = fixture("app_with_wrapper")
+				requests = 0
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					if username, password, ok := r.BasicAuth(); !ok || username != "jdoe" || password != "s3cret" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					if requests <= len(statuses) {
+						w.WriteHeader(statuses[requests-1])
+						return
+					}
+					fmt.Fprint(w, `<settings><servers/></settings>`)
+				}))
+				os.Setenv("MAVEN_SETTINGS_URL", server.URL+"/settings.xml")
+				os.Setenv("MAVEN_SETTINGS_USERNAME", "jdoe")
+				os.Setenv("MAVEN_SETTINGS_PASSWORD", "s3cret")
+				restore = maven.SetSettingsDownloadBackoff(0)
+			})
+
+			it("should retry after a server error", func() {
+				statuses = []int{http.StatusServiceUnavailable, http.StatusBadGateway}
+
+				if err := runner.Init(appDir, layersDir); err != nil {
+					t.Fatal(err)
+				}
+				if requests != 3 {
+					t.Fatalf("expected 3 requests, got %d", requests)
+				}
+			})
+
+			it("should not retry after a client error", func() {
+				statuses = []int{http.StatusNotFound}
+
+				if err := runner.Init(appDir, layersDir); err == nil {
+					t.Fatal("unexpected success")
+				}
+				if requests != 1 {
+					t.Fatalf("expected 1 request, got %d", requests)
+				}
+			})
+
+			it("should use basic auth", func() {
+				statuses = nil
+				os.Setenv("MAVEN_SETTINGS_PASSWORD", "wrong")
+
+				if err := runner.Init(appDir, layersDir); err == nil {
+					t.Fatal("unexpected success with the wrong password")
+				}
+				if requests != 1 {
+					t.Fatalf("expected 1 request, got %d", requests)
+				}
+			})
+
+			it.After(func() {
+				restore()
+				server.Close()
+				os.Unsetenv("MAVEN_SETTINGS_URL")
+				os.Unsetenv("MAVEN_SETTINGS_USERNAME")
+				os.Unsetenv("MAVEN_SETTINGS_PASSWORD")
+			})
+		})
+
+		when("MAVEN_REPOSITORY_* is set", func() {
+			it.Before(func() {
+				appDir = fixture("app_with_settings")
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_URL", "https://repo.example.com/maven2")
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_USERNAME", "jdoe")
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_PASSWORD", "s3cr3t&")
+				os.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+				os.Setenv("NO_PROXY", "localhost,.internal")
+			})
+
+			it("should use the app settings as global settings", func() {
+				if err := runner.Init(appDir, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if optionValue(runner.Options, "-gs") != "settings.xml" {
+					t.Fatalf(`runner options do not use the app settings as global settings: \n%s`, runner.Options)
+				}
+
+				generated := optionValue(runner.Options, "-s")
+				if !strings.HasPrefix(generated, layersDir.Root) {
+					t.Fatalf(`generated settings are not in the layers dir: %s`, generated)
+				}
+				if _, err := os.Stat(generated); !os.IsNotExist(err) {
+					t.Fatal("generated settings were written by Init")
+				}
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_URL")
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_USERNAME")
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_PASSWORD")
+				os.Unsetenv("HTTPS_PROXY")
+				os.Unsetenv("NO_PROXY")
+			})
+		})
+
+		when("only HTTPS_PROXY is set", func() {
+			it.Before(func() {
+				appDir = fixture("app_with_settings")
+				os.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+			})
+
+			it("should keep the app settings as user settings", func() {
+				if err := runner.Init(appDir, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if optionValue(runner.Options, "-s") != "settings.xml" {
+					t.Fatalf(`runner options do not use the app settings: \n%s`, runner.Options)
+				}
+				if !strings.HasPrefix(optionValue(runner.Options, "-gs"), layersDir.Root) {
+					t.Fatalf(`runner options do not use the generated settings as global settings: \n%s`, runner.Options)
+				}
+			})
+
+			it.After(func() {
+				os.Unsetenv("HTTPS_PROXY")
+			})
+		})
+
+		when("toolchain JDKs are installed", func() {
+			it.Before(func() {
+				appDir = fixture("app_with_wrapper")
+				for name, version := range map[string]jdk.Version{
+					"jdk":             {Major: "11", Tag: "11.0.3", Vendor: "openjdk"},
+					"toolchain-jdk-8": {Major: "8", Tag: "1.8.0_212", Vendor: "openjdk"},
+				} {
+					layer := layersDir.Layer(name)
+					if err := layer.WriteMetadata(jdk.Jvm{Home: layer.Root, Version: version}, layers.Build); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+
+			it("should point Maven at the toolchains without writing them", func() {
+				if err := runner.Init(appDir, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				generated := optionValue(runner.Options, "-t")
+				if !strings.HasPrefix(generated, layersDir.Root) {
+					t.Fatalf(`generated toolchains are not in the layers dir: %s`, generated)
+				}
+				if _, err := os.Stat(generated); !os.IsNotExist(err) {
+					t.Fatal("generated toolchains were written by Init")
+				}
+			})
+		})
+
 		when("MAVEN_CUSTOM_GOALS is set", func() {
 			appDir = fixture("app_with_wrapper")
 
@@ -150,6 +399,587 @@ func testMaven(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("#Run", func() {
+		var (
+			home    string
+			oldHome string
+		)
+
+		it.Before(func() {
+			var err error
+			home, err = ioutil.TempDir("", "home")
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldHome = os.Getenv("HOME")
+			os.Setenv("HOME", home)
+		})
+
+		it.After(func() {
+			os.Setenv("HOME", oldHome)
+			os.RemoveAll(home)
+		})
+
+		when("~/.m2 does not exist", func() {
+			it("should remove the link to the cache layer even when the build fails", func() {
+				runner.Out = ioutil.Discard
+
+				if err := runner.Run(fixture("app_with_compile_error"), "clean install", []string{}, layersDir); err == nil {
+					t.Fatal("Expected the build to fail")
+				}
+
+				if _, err := os.Lstat(filepath.Join(home, ".m2")); !os.IsNotExist(err) {
+					t.Fatal("Expected ~/.m2 to be removed")
+				}
+			})
+
+			it("should replace a link left behind by a previous build", func() {
+				runner.Out = ioutil.Discard
+
+				if err := os.MkdirAll(layersDir.Layer("maven_m2").Root, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(layersDir.Layer("maven_m2").Root, filepath.Join(home, ".m2")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := os.Lstat(filepath.Join(home, ".m2")); !os.IsNotExist(err) {
+					t.Fatal("Expected ~/.m2 to be removed")
+				}
+			})
+		})
+
+		when("~/.m2 already exists", func() {
+			it("should merge its repository into the cache layer and leave it in place", func() {
+				runner.Out = ioutil.Discard
+
+				existing := filepath.Join(home, ".m2", "repository", "org", "example", "lib", "1.0", "lib-1.0.jar")
+				if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(existing, []byte("artifact"), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				repository := filepath.Join(layersDir.Layer("maven_m2").Root, "repository")
+				if optionValue(runner.Options, "-Dmaven.repo.local") != repository {
+					t.Fatalf(`runner options do not use the cache layer: \n%s`, runner.Options)
+				}
+
+				if _, err := os.Stat(filepath.Join(repository, "org", "example", "lib", "1.0", "lib-1.0.jar")); err != nil {
+					t.Fatalf(`Expected the existing artifact to be merged into the cache: %s`, err)
+				}
+
+				if _, err := os.Stat(existing); err != nil {
+					t.Fatalf(`Expected ~/.m2 to be left in place: %s`, err)
+				}
+			})
+		})
+
+		when("MAVEN_INCREMENTAL is set", func() {
+			var appDir string
+
+			it.Before(func() {
+				os.Setenv("MAVEN_INCREMENTAL", "true")
+				appDir = copyFixture(t, "app_with_modules")
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_INCREMENTAL")
+				os.RemoveAll(appDir)
+			})
+
+			it("should only reuse the target directories of unchanged modules", func() {
+				runner.Out = ioutil.Discard
+
+				if err := runner.Run(appDir, "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(runner.Goals) != 1 || runner.Goals[0] != "install" {
+					t.Fatalf(`runner goals should not clean: %s`, runner.Goals)
+				}
+
+				// the next build starts from the sources, with a change in one module
+				for _, module := range []string{"common", "service"} {
+					if err := os.RemoveAll(filepath.Join(appDir, module, "target")); err != nil {
+						t.Fatal(err)
+					}
+				}
+				pom, err := os.OpenFile(filepath.Join(appDir, "common", "pom.xml"), os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pom.WriteString("<!-- changed -->\n")
+				pom.Close()
+
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+				if err := runner.Run(appDir, "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := os.Stat(filepath.Join(appDir, "service", "target", "service-1.0-SNAPSHOT.jar")); err != nil {
+					t.Fatalf(`Expected the target directory of the unchanged module to be restored: %s`, err)
+				}
+				if _, err := os.Stat(filepath.Join(appDir, "common", "target")); !os.IsNotExist(err) {
+					t.Fatal("Expected the target directory of the changed module not to be restored")
+				}
+				if !strings.Contains(stdout.String(), "reusing target/ of 1 unchanged modules, rebuilding ., common") {
+					t.Fatalf(`Did not log the rebuilt modules: \n%s`, stdout.String())
+				}
+			})
+
+			it("should rebuild the modules that depend on a changed module", func() {
+				runner.Out = ioutil.Discard
+
+				servicePom := filepath.Join(appDir, "service", "pom.xml")
+				contents, err := ioutil.ReadFile(servicePom)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dependency := `<dependencies><dependency><groupId>${project.groupId}</groupId><artifactId>common</artifactId></dependency></dependencies></project>`
+				if err := ioutil.WriteFile(servicePom, []byte(strings.Replace(string(contents), "</project>", dependency, 1)), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := runner.Run(appDir, "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, module := range []string{"common", "service"} {
+					if err := os.RemoveAll(filepath.Join(appDir, module, "target")); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := ioutil.WriteFile(filepath.Join(appDir, "common", "Changed.java"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+				if err := runner.Run(appDir, "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := os.Stat(filepath.Join(appDir, "service", "target")); !os.IsNotExist(err) {
+					t.Fatal("Expected the target directory of the dependent module not to be restored")
+				}
+				if !strings.Contains(stdout.String(), "rebuilding ., common, service") {
+					t.Fatalf(`Did not log the rebuilt modules: \n%s`, stdout.String())
+				}
+			})
+		})
+
+		when("the app has a pre-seeded repository", func() {
+			it("should build offline and report the missing artifact", func() {
+				runner.Out = ioutil.Discard
+
+				err := runner.Run(fixture("app_with_seeded_repo"), "clean install", []string{}, layersDir)
+				if err == nil {
+					t.Fatal("Expected the build to fail")
+				}
+
+				if !hasOption(runner.Options, "-o") {
+					t.Fatalf(`runner options are not offline: \n%s`, runner.Options)
+				}
+
+				if !strings.Contains(err.Error(), "missing org.example:missing:jar:2.0") {
+					t.Fatalf(`Did not report the missing artifact: \n%s`, err.Error())
+				}
+
+				seeded := filepath.Join(layersDir.Layer("maven_m2").Root, "repository", "org", "example", "lib", "1.0", "lib-1.0.jar")
+				if _, err := os.Stat(seeded); err != nil {
+					t.Fatalf(`Expected the seeded artifact in the cache: %s`, err)
+				}
+			})
+		})
+
+		when("MAVEN_REPOSITORY_* is set", func() {
+			it.Before(func() {
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_URL", "https://repo.example.com/maven2")
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_USERNAME", "jdoe")
+				os.Setenv("MAVEN_REPOSITORY_MY_CORP_PASSWORD", "s3cr3t&")
+				os.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+				os.Setenv("NO_PROXY", "localhost,.internal")
+			})
+
+			it("should pass the generated settings to Maven and remove them afterwards", func() {
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+
+				if err := runner.Run(fixture("app_with_generated_settings"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, expected := range []string{
+					"<id>my-corp</id>",
+					"<url>https://repo.example.com/maven2</url>",
+					"<password>s3cr3t&amp;</password>",
+					"<host>proxy.example.com</host>",
+					"<nonProxyHosts>localhost|*.internal</nonProxyHosts>",
+					"<activeProfile>heroku-env-repositories</activeProfile>",
+				} {
+					if !strings.Contains(stdout.String(), expected) {
+						t.Fatalf(`generated settings do not contain %s: \n%s`, expected, stdout.String())
+					}
+				}
+
+				if _, err := os.Stat(optionValue(runner.Options, "-s")); !os.IsNotExist(err) {
+					t.Fatal("generated settings were not removed")
+				}
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_URL")
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_USERNAME")
+				os.Unsetenv("MAVEN_REPOSITORY_MY_CORP_PASSWORD")
+				os.Unsetenv("HTTPS_PROXY")
+				os.Unsetenv("NO_PROXY")
+			})
+		})
+
+		when("toolchain JDKs are installed", func() {
+			it.Before(func() {
+				for name, version := range map[string]jdk.Version{
+					"jdk":             {Major: "11", Tag: "11.0.3", Vendor: "openjdk"},
+					"toolchain-jdk-8": {Major: "8", Tag: "1.8.0_212", Vendor: "openjdk"},
+				} {
+					layer := layersDir.Layer(name)
+					if err := layer.WriteMetadata(jdk.Jvm{Home: layer.Root, Version: version}, layers.Build); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+
+			it("should pass toolchains for every JDK to Maven and remove them afterwards", func() {
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+
+				if err := runner.Run(fixture("app_with_generated_settings"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, expected := range []string{
+					"<version>11</version>",
+					fmt.Sprintf("<jdkHome>%s</jdkHome>", layersDir.Layer("jdk").Root),
+					"<version>1.8</version>",
+					fmt.Sprintf("<jdkHome>%s</jdkHome>", layersDir.Layer("toolchain-jdk-8").Root),
+				} {
+					if !strings.Contains(stdout.String(), expected) {
+						t.Fatalf(`generated toolchains do not contain %s: \n%s`, expected, stdout.String())
+					}
+				}
+
+				if _, err := os.Stat(optionValue(runner.Options, "-t")); !os.IsNotExist(err) {
+					t.Fatal("generated toolchains were not removed")
+				}
+			})
+		})
+
+		when("the app fails to compile", func() {
+			it("should summarize the compilation errors", func() {
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+
+				err := runner.Run(fixture("app_with_compile_error"), "clean install", []string{}, layersDir)
+				if err == nil {
+					t.Fatal("unexpected success")
+				}
+
+				for _, expected := range []string{
+					"Failed to build app with Maven: the app failed to compile",
+					"\n  src/main/java/Main.java:5: cannot find symbol\n\n",
+					"Fix the compilation errors listed above",
+				} {
+					if !strings.Contains(err.Error(), expected) {
+						t.Fatalf(`error does not contain %q: \n%s`, expected, err.Error())
+					}
+				}
+
+				if !strings.Contains(stdout.String(), "[INFO] BUILD FAILURE") {
+					t.Fatalf(`raw Maven output was not streamed: \n%s`, stdout.String())
+				}
+			})
+		})
+
+		when("the app has several modules", func() {
+			it.Before(func() {
+				os.Setenv("MAVEN_PROJECTS", ":service")
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_PROJECTS")
+			})
+
+			it("should build the selected module and record its artifacts", func() {
+				runner.Out = ioutil.Discard
+
+				err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if optionValue(runner.Options, "-pl") != ":service" || !hasOption(runner.Options, "-am") {
+					t.Fatalf(`runner options do not select the module: \n%s`, runner.Options)
+				}
+
+				metadata, err := maven.ReadBuildMetadata(layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(metadata.Modules) != 3 {
+					t.Fatalf(`Did not find every module: got %d, want %d`, len(metadata.Modules), 3)
+				}
+
+				deployable := metadata.DeployableModules()
+				if len(deployable) != 1 || deployable[0].Path != "service" || !deployable[0].Selected {
+					t.Fatalf(`Did not find the deployable module: %v`, deployable)
+				}
+
+				expected := filepath.Join("service", "target", "service-1.0-SNAPSHOT.jar")
+				if deployable[0].Artifacts[0] != expected {
+					t.Fatalf(`Did not record the artifact: got %s, want %s`, deployable[0].Artifacts[0], expected)
+				}
+			})
+
+			it("should record the dependencies of every module", func() {
+				runner.Out = ioutil.Discard
+
+				err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				metadata, err := maven.ReadBuildMetadata(layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(metadata.Dependencies()) != 5 {
+					t.Fatalf(`Did not find every dependency: got %d, want %d`, len(metadata.Dependencies()), 5)
+				}
+
+				postgres, found := metadata.FindDependency("org.postgresql", "postgresql")
+				if !found {
+					t.Fatal("Did not find the postgresql dependency")
+				}
+				if postgres.Version != "42.2.5" || postgres.Scope != "runtime" || !postgres.Optional {
+					t.Fatalf(`Did not parse the postgresql dependency: %v`, postgres)
+				}
+
+				epoll, found := metadata.FindDependency("io.netty", "netty-transport-native-epoll")
+				if !found || epoll.Classifier != "linux-x86_64" || epoll.Version != "4.1.30.Final" {
+					t.Fatalf(`Did not parse the classifier of the netty dependency: %v`, epoll)
+				}
+			})
+		})
+
+		when("a module's pom.xml can't be read", func() {
+			var appDir string
+
+			it.Before(func() {
+				appDir = copyFixture(t, "app_with_modules")
+				if err := ioutil.WriteFile(filepath.Join(appDir, "service", "pom.xml"), []byte("<project>"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_PROJECTS")
+				os.RemoveAll(appDir)
+			})
+
+			it("should report the module and only record the root project", func() {
+				var stderr bytes.Buffer
+				runner.Out, runner.Err = ioutil.Discard, &stderr
+
+				if err := runner.Run(appDir, "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if !strings.Contains(stderr.String(), "module service") {
+					t.Fatalf(`Did not report the module: \n%s`, stderr.String())
+				}
+
+				metadata, err := maven.ReadBuildMetadata(layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(metadata.Modules) != 1 || metadata.Modules[0].Path != "." {
+					t.Fatalf(`Did not fall back to the root project: %v`, metadata.Modules)
+				}
+			})
+
+			it("should fail when MAVEN_PROJECTS selects modules", func() {
+				os.Setenv("MAVEN_PROJECTS", ":service")
+				runner.Out = ioutil.Discard
+
+				err := runner.Run(appDir, "clean install", []string{}, layersDir)
+				if err == nil || !strings.Contains(err.Error(), "module service") {
+					t.Fatalf("expected an error about the service module, got %v", err)
+				}
+			})
+		})
+
+		when("the Maven cache has old artifacts", func() {
+			var repository string
+
+			it.Before(func() {
+				repository = filepath.Join(layersDir.Layer("maven_m2").Root, "repository")
+				for _, file := range []string{
+					"org/old/old/1.0/old-1.0.jar",
+					"org/slf4j/slf4j-api/1.7.25/slf4j-api-1.7.25.jar",
+					"org/slf4j/slf4j-api/1.7.25/_remote.repositories",
+					"org/missing/missing/1.0/missing-1.0.jar.lastUpdated",
+					"com/mycompany/lib/1.0-SNAPSHOT/lib-1.0-20190101.120000-1.jar",
+					"com/mycompany/lib/1.0-SNAPSHOT/lib-1.0-20190102.120000-2.jar",
+				} {
+					path := filepath.Join(repository, file)
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := ioutil.WriteFile(path, []byte("artifact"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				manifest := struct {
+					Builds    int            `toml:"builds"`
+					Artifacts map[string]int `toml:"artifacts"`
+				}{10, map[string]int{
+					"org/old/old/1.0":                2,
+					"org/slf4j/slf4j-api/1.7.25":     2,
+					"com/mycompany/lib/1.0-SNAPSHOT": 10,
+				}}
+				if err := layersDir.Layer("maven_m2").WriteMetadata(manifest, layers.Cache); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			it("should remove debris and the artifacts that are no longer used", func() {
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+
+				err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for file, kept := range map[string]bool{
+					"org/old": false,
+					"org/slf4j/slf4j-api/1.7.25/slf4j-api-1.7.25.jar":              true,
+					"org/slf4j/slf4j-api/1.7.25/_remote.repositories":              false,
+					"org/missing/missing/1.0/missing-1.0.jar.lastUpdated":          false,
+					"com/mycompany/lib/1.0-SNAPSHOT/lib-1.0-20190101.120000-1.jar": false,
+					"com/mycompany/lib/1.0-SNAPSHOT/lib-1.0-20190102.120000-2.jar": true,
+				} {
+					_, err := os.Stat(filepath.Join(repository, file))
+					if kept && err != nil {
+						t.Fatalf(`Expected %s to be kept: %s`, file, err)
+					} else if !kept && !os.IsNotExist(err) {
+						t.Fatalf(`Expected %s to be removed`, file)
+					}
+				}
+
+				if !strings.Contains(stdout.String(), "(1 unused artifacts removed)") {
+					t.Fatalf(`Did not log the cache size: \n%s`, stdout.String())
+				}
+			})
+
+			it("should evict the least recently used artifacts above MAVEN_CACHE_MAX_SIZE", func() {
+				os.Setenv("MAVEN_CACHE_MAX_SIZE", "10")
+				defer os.Unsetenv("MAVEN_CACHE_MAX_SIZE")
+
+				runner.Out = ioutil.Discard
+
+				err := runner.Run(fixture("app_with_modules"), "clean install", []string{}, layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := os.Stat(filepath.Join(repository, "com", "mycompany", "lib")); !os.IsNotExist(err) {
+					t.Fatal("Expected the least recently used artifact to be evicted")
+				}
+				if _, err := os.Stat(filepath.Join(repository, "org", "slf4j", "slf4j-api", "1.7.25")); err != nil {
+					t.Fatalf("Expected the dependency used by this build to be kept: %s", err)
+				}
+			})
+		})
+
+		when("MAVEN_RUN_TESTS is set", func() {
+			it.Before(func() {
+				os.Setenv("MAVEN_RUN_TESTS", "true")
+			})
+
+			it.After(func() {
+				os.Unsetenv("MAVEN_RUN_TESTS")
+			})
+
+			it("should run the tests and summarize the reports of every module", func() {
+				var stdout bytes.Buffer
+				runner.Out = &stdout
+
+				err := runner.Run(fixture("app_with_test_reports"), "clean install", []string{"-DskipTests"}, layersDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if hasOption(runner.Options, "-DskipTests") {
+					t.Fatalf(`runner options still skip tests: \n%s`, runner.Options)
+				}
+
+				for _, expected := range []string{
+					"Tests: 2 passed, 1 failed, 1 errors, 1 skipped (5 total)",
+					"FAILED com.example.AppTest.shouldFail",
+					"FAILED com.example.ServiceIT.shouldConnect",
+				} {
+					if !strings.Contains(stdout.String(), expected) {
+						t.Fatalf(`output does not contain %q: \n%s`, expected, stdout.String())
+					}
+				}
+				if strings.Contains(stdout.String(), "RemovedTest") {
+					t.Fatalf(`output contains the report of a previous build: \n%s`, stdout.String())
+				}
+
+				report, err := ioutil.ReadFile(filepath.Join(layersDir.Layer("test-reports").Root, "TEST-aggregate.xml"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(report), `<testsuites tests="5" failures="1" errors="1" skipped="1"`) {
+					t.Fatalf(`aggregate report does not have the totals: \n%s`, report)
+				}
+			})
+
+			it("should remove the reports of a previous build when the tests don't run", func() {
+				if err := runner.Run(fixture("app_with_test_reports"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				os.Unsetenv("MAVEN_RUN_TESTS")
+				if err := runner.Run(fixture("app_with_test_reports"), "clean install", []string{}, layersDir); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := os.Stat(layersDir.Layer("test-reports").Root); !os.IsNotExist(err) {
+					t.Fatal("test reports layer was not removed")
+				}
+				if _, err := os.Stat(layersDir.Layer("test-reports").Root + ".toml"); !os.IsNotExist(err) {
+					t.Fatal("test reports layer metadata was not removed")
+				}
+			})
+		})
+	})
 }
 
 func hasOption(opts []string, opt string) bool {
@@ -161,6 +991,48 @@ func hasOption(opts []string, opt string) bool {
 	return false
 }
 
+func optionValue(opts []string, opt string) string {
+	for i, b := range opts {
+		if b == opt && i+1 < len(opts) {
+			return opts[i+1]
+		} else if strings.HasPrefix(b, opt+"=") {
+			return strings.TrimPrefix(b, opt+"=")
+		}
+	}
+	return ""
+}
+
+// copyFixture copies a fixture to a temporary directory, for the tests that change the app.
+func copyFixture(t *testing.T, name string) string {
+	dir, err := ioutil.TempDir("", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fixture(name)
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), info.Mode())
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), data, info.Mode())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
 func fixture(name string) string {
 	wd, _ := os.Getwd()
 	return filepath.Join(wd, "..", "test", "fixtures", name)
@@ -0,0 +1,39 @@
+package maven
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	seededRepositoryDir         = ".m2-repo"
+	seededRepositoryBindingType = "maven-repository"
+)
+
+// seededRepository finds a repository that was populated before the build, either vendored with the app in .m2-repo
+// or provided as a binding of type maven-repository. When there is one, Maven runs offline, so that the build only
+// uses the artifacts it contains.
+func seededRepository(appDir string) string {
+	vendored := filepath.Join(appDir, seededRepositoryDir)
+	if info, err := os.Stat(vendored); err == nil && info.IsDir() {
+		return vendored
+	}
+
+	bindingRoot, isSet := os.LookupEnv("SERVICE_BINDING_ROOT")
+	if !isSet {
+		return ""
+	}
+	bindings, err := ioutil.ReadDir(bindingRoot)
+	if err != nil {
+		return ""
+	}
+	for _, binding := range bindings {
+		bindingType, err := ioutil.ReadFile(filepath.Join(bindingRoot, binding.Name(), "type"))
+		if err == nil && strings.TrimSpace(string(bindingType)) == seededRepositoryBindingType {
+			return filepath.Join(bindingRoot, binding.Name())
+		}
+	}
+	return ""
+}
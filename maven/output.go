@@ -0,0 +1,210 @@
+package maven
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const maxFailureDetails = 10
+
+type failureKind struct {
+	Name       string
+	Suggestion string
+}
+
+// failureKinds are listed in order of precedence: when a build fails for several reasons, the first one is usually
+// the root cause (e.g. an unauthorized repository leads to unresolved dependencies).
+var (
+	outOfMemory = failureKind{
+		Name:       "Maven ran out of memory",
+		Suggestion: "Increase the heap available to Maven, e.g. with MAVEN_CUSTOM_OPTS or by setting -Xmx in MAVEN_OPTS.",
+	}
+	unauthorized = failureKind{
+		Name:       "access to a Maven repository was denied",
+		Suggestion: "Check the credentials for the repository, e.g. with MAVEN_REPOSITORY_<ID>_USERNAME and MAVEN_REPOSITORY_<ID>_PASSWORD, or in your settings.xml.",
+	}
+	missingOfflineArtifacts = failureKind{
+		Name:       "artifacts are missing from the pre-seeded repository",
+		Suggestion: "The build runs offline because a repository was provided in .m2-repo or with a maven-repository binding. Add the missing artifacts to it, e.g. with mvn dependency:go-offline -Dmaven.repo.local=.m2-repo.",
+	}
+	unresolvedDependencies = failureKind{
+		Name:       "dependencies could not be resolved",
+		Suggestion: "Check that the dependencies exist in the repositories declared in your pom.xml or settings.xml, and that the versions are correct.",
+	}
+	compilationErrors = failureKind{
+		Name:       "the app failed to compile",
+		Suggestion: "Fix the compilation errors listed above, and check that the JDK version in system.properties matches the one your code targets.",
+	}
+	enforcerViolations = failureKind{
+		Name:       "Maven Enforcer rules were violated",
+		Suggestion: "Update the build to satisfy the rules above. If a rule requires a Java version, set java.runtime.version in system.properties.",
+	}
+	testFailures = failureKind{
+		Name:       "tests failed",
+		Suggestion: "Fix the failing tests listed above, or skip them with MAVEN_CUSTOM_OPTS=\"-DskipTests\".",
+	}
+
+	failureKinds = []failureKind{outOfMemory, unauthorized, missingOfflineArtifacts, unresolvedDependencies, compilationErrors, enforcerViolations, testFailures}
+)
+
+type outputMatcher struct {
+	Kind    failureKind
+	Pattern *regexp.Regexp
+	// Detail formats the submatches into a line of the summary. Matchers without one only identify the failure.
+	Detail func(m []string) string
+}
+
+var outputMatchers = []outputMatcher{
+	{
+		Kind:    outOfMemory,
+		Pattern: regexp.MustCompile(`java\.lang\.OutOfMemoryError(?::\s*(.*))?`),
+		Detail:  func(m []string) string { return strings.TrimSpace("OutOfMemoryError: " + m[1]) },
+	},
+	{
+		Kind:    unauthorized,
+		Pattern: regexp.MustCompile(`(?i)\((https?://[^)\s]+)\).*(?:status code: 40[13]|not authorized|reasonphrase: ?(?:unauthorized|forbidden))`),
+		Detail:  func(m []string) string { return fmt.Sprintf("denied by %s", m[1]) },
+	},
+	{
+		Kind:    unauthorized,
+		Pattern: regexp.MustCompile(`(?i)(?:status code: 40[13]|not authorized|authorization failed|reasonphrase: ?(?:unauthorized|forbidden))`),
+	},
+	{
+		Kind:    missingOfflineArtifacts,
+		Pattern: regexp.MustCompile(`in offline mode and the artifact (\S+) has not been downloaded from it before`),
+		Detail:  func(m []string) string { return fmt.Sprintf("missing %s", m[1]) },
+	},
+	{
+		Kind:    unresolvedDependencies,
+		Pattern: regexp.MustCompile(`Could not (?:find|resolve|transfer) artifact (\S+)`),
+		Detail:  func(m []string) string { return fmt.Sprintf("missing %s", m[1]) },
+	},
+	{
+		Kind:    unresolvedDependencies,
+		Pattern: regexp.MustCompile(`Could not resolve dependencies for project (\S+)`),
+	},
+	{
+		Kind:    compilationErrors,
+		Pattern: regexp.MustCompile(`^\[ERROR\] (\S+\.(?:java|kt|groovy|scala)):\[(\d+),\d+\] (.*)$`),
+		Detail:  func(m []string) string { return fmt.Sprintf("%s:%s: %s", m[1], m[2], m[3]) },
+	},
+	{
+		Kind:    compilationErrors,
+		Pattern: regexp.MustCompile(`COMPILATION ERROR`),
+	},
+	{
+		Kind:    enforcerViolations,
+		Pattern: regexp.MustCompile(`Rule \d+: (\S+) failed with message:?`),
+		Detail:  func(m []string) string { return m[1] },
+	},
+	{
+		Kind:    enforcerViolations,
+		Pattern: regexp.MustCompile(`Some Enforcer rules have failed`),
+	},
+	{
+		Kind:    testFailures,
+		Pattern: regexp.MustCompile(`^\[ERROR\]\s{2,}(\S+\.\S+:\d+\b.*)$`),
+		Detail:  func(m []string) string { return m[1] },
+	},
+	{
+		Kind:    testFailures,
+		Pattern: regexp.MustCompile(`There (?:are|were) test failures|Tests run: \d+, Failures: [1-9]`),
+	},
+}
+
+// outputParser watches Maven's output for known failures. It is written to alongside the real output, so the raw
+// output is still streamed to the user as it happens.
+type outputParser struct {
+	appDir  string
+	mutex   sync.Mutex
+	found   map[string]bool
+	details map[string][]string
+}
+
+type outputStream struct {
+	parser *outputParser
+	buffer bytes.Buffer
+}
+
+func newOutputParser(appDir string) *outputParser {
+	return &outputParser{
+		appDir:  appDir,
+		found:   map[string]bool{},
+		details: map[string][]string{},
+	}
+}
+
+// Writer returns a writer for one of Maven's output streams. Each stream is split into lines separately, so that
+// stdout and stderr can be written to concurrently.
+func (p *outputParser) Writer() *outputStream {
+	return &outputStream{parser: p}
+}
+
+func (s *outputStream) Write(data []byte) (int, error) {
+	s.buffer.Write(data)
+	for {
+		line, err := s.buffer.ReadString('\n')
+		if err != nil {
+			// keep the incomplete line until the rest of it is written
+			s.buffer.Reset()
+			s.buffer.WriteString(line)
+			break
+		}
+		s.parser.parseLine(strings.TrimRight(line, "\r\n"))
+	}
+	return len(data), nil
+}
+
+// Close parses whatever is left after the last line break.
+func (s *outputStream) Close() error {
+	if s.buffer.Len() > 0 {
+		s.parser.parseLine(s.buffer.String())
+		s.buffer.Reset()
+	}
+	return nil
+}
+
+func (p *outputParser) parseLine(line string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, matcher := range outputMatchers {
+		m := matcher.Pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		p.found[matcher.Kind.Name] = true
+		if matcher.Detail != nil {
+			// paths in the output are absolute, but relative ones are easier to read in the summary
+			detail := strings.Replace(matcher.Detail(m), p.appDir+"/", "", -1)
+			p.addDetail(matcher.Kind, detail)
+		}
+		return
+	}
+}
+
+func (p *outputParser) addDetail(kind failureKind, detail string) {
+	for _, existing := range p.details[kind.Name] {
+		if existing == detail {
+			return
+		}
+	}
+	p.details[kind.Name] = append(p.details[kind.Name], detail)
+}
+
+// Failure returns the most relevant failure found in the output, if any.
+func (p *outputParser) Failure() (failureKind, []string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, kind := range failureKinds {
+		if p.found[kind.Name] {
+			return kind, p.details[kind.Name], true
+		}
+	}
+	return failureKind{}, nil, false
+}
@@ -0,0 +1,202 @@
+package maven
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+)
+
+const buildMetadataLayer = "maven_build"
+
+// Module is a project in the Maven reactor. Path is relative to the app directory, and is "." for the root project.
+type Module struct {
+	Path         string       `toml:"path"`
+	GroupId      string       `toml:"group_id"`
+	ArtifactId   string       `toml:"artifact_id"`
+	Version      string       `toml:"version"`
+	Packaging    string       `toml:"packaging"`
+	Selected     bool         `toml:"selected"`
+	Artifacts    []string     `toml:"artifacts"`
+	Dependencies []Dependency `toml:"dependencies"`
+
+	// declared are the dependencies in the module's pom, which are known before the build
+	declared []util.PomDependency
+}
+
+// BuildMetadata describes what the Maven build produced, for the steps that run after it. It's written to a layer
+// that is neither cached nor exported, so it only lives for the duration of the build.
+type BuildMetadata struct {
+	Modules []Module `toml:"modules"`
+}
+
+func ReadBuildMetadata(layersDir layers.Layers) (BuildMetadata, error) {
+	var metadata BuildMetadata
+	err := layersDir.Layer(buildMetadataLayer).ReadMetadata(&metadata)
+	return metadata, err
+}
+
+// DeployableModules returns the modules with artifacts, with the ones selected by MAVEN_PROJECTS first.
+func (m BuildMetadata) DeployableModules() []Module {
+	var selected, others []Module
+	for _, module := range m.Modules {
+		if len(module.Artifacts) == 0 {
+			continue
+		}
+		if module.Selected {
+			selected = append(selected, module)
+		} else {
+			others = append(others, module)
+		}
+	}
+	return append(selected, others...)
+}
+
+// ReadReactor lists the root project and all of its modules, recursively, in the order they are declared.
+func ReadReactor(appDir string) ([]Module, error) {
+	return readReactorModule(appDir, ".", map[string]bool{})
+}
+
+func readReactorModule(appDir, path string, seen map[string]bool) ([]Module, error) {
+	path = filepath.Clean(path)
+	if seen[path] {
+		return nil, nil
+	}
+	seen[path] = true
+
+	pomFile := filepath.Join(appDir, path)
+	if info, err := os.Stat(pomFile); err == nil && info.IsDir() {
+		pomFile = filepath.Join(pomFile, "pom.xml")
+	} else {
+		// a <module> can name the pom file itself, rather than its directory
+		path = filepath.Dir(path)
+	}
+
+	pom, err := util.ReadPomFile(pomFile)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %s", path, err)
+	}
+
+	modules := []Module{{
+		Path:       path,
+		GroupId:    pom.GroupId,
+		ArtifactId: pom.ArtifactId,
+		Version:    pom.Version,
+		Packaging:  pom.Packaging,
+		declared:   pom.Dependencies,
+	}}
+
+	for _, child := range pom.Modules {
+		children, err := readReactorModule(appDir, filepath.Join(path, strings.TrimSpace(child)), seen)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, children...)
+	}
+	return modules, nil
+}
+
+// readModules reads the reactor for the steps of the build that fall back to the root project alone when it can't be
+// read, which is expected of polyglot projects since they don't have a pom.xml. Other failures are reported, and fail
+// the build when MAVEN_PROJECTS selects modules, since the selected ones might be missing.
+func (r *Runner) readModules(appDir string) ([]Module, error) {
+	modules, err := ReadReactor(appDir)
+	if err == nil {
+		return modules, nil
+	}
+
+	if _, statErr := os.Stat(filepath.Join(appDir, "pom.xml")); statErr == nil {
+		if len(selectedProjects()) > 0 {
+			return nil, failedToReadSelectedModules(err)
+		}
+		fmt.Fprintf(r.Err, "Failed to read the Maven modules, only the root project is known: %s\n", err)
+	}
+	return []Module{{Path: "."}}, nil
+}
+
+// selectedProjects reads the MAVEN_PROJECTS selection, in the same format as Maven's --projects option.
+func selectedProjects() []string {
+	var projects []string
+	for _, project := range strings.Split(os.Getenv("MAVEN_PROJECTS"), ",") {
+		if project = strings.TrimSpace(project); project != "" {
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// projectsOption finds the modules selected with -pl or --projects, whether they came from MAVEN_PROJECTS or were
+// passed directly in MAVEN_CUSTOM_OPTS.
+func projectsOption(opts []string) []string {
+	var projects []string
+	for i, opt := range opts {
+		if (opt == "-pl" || opt == "--projects") && i+1 < len(opts) {
+			for _, project := range strings.Split(opts[i+1], ",") {
+				projects = append(projects, strings.TrimSpace(project))
+			}
+		}
+	}
+	return projects
+}
+
+func (m Module) matches(project string) bool {
+	switch {
+	case strings.HasPrefix(project, ":"):
+		return project[1:] == m.ArtifactId
+	case strings.Contains(project, ":"):
+		return project == m.GroupId+":"+m.ArtifactId
+	default:
+		return filepath.Clean(project) == m.Path
+	}
+}
+
+// recordArtifacts reports which module produced which artifact, and saves it in the build metadata along with the
+// dependencies of each module, so that the releaser can find the deployable module.
+func (r *Runner) recordArtifacts(appDir string, layersDir layers.Layers) error {
+	modules, err := r.readModules(appDir)
+	if err != nil {
+		return err
+	}
+
+	projects := projectsOption(r.Options)
+	for i := range modules {
+		for _, project := range projects {
+			if modules[i].matches(project) {
+				modules[i].Selected = true
+			}
+		}
+
+		artifacts, err := filepath.Glob(filepath.Join(appDir, modules[i].Path, "target", "*.[jw]ar"))
+		if err != nil {
+			return err
+		}
+		for _, artifact := range artifacts {
+			rel, err := filepath.Rel(appDir, artifact)
+			if err != nil {
+				return err
+			}
+			modules[i].Artifacts = append(modules[i].Artifacts, rel)
+		}
+
+		modules[i].Dependencies, err = readModuleDependencies(appDir, modules[i])
+		if err != nil {
+			fmt.Fprintf(r.Err, "Failed to read the dependencies of %s: %s\n", modules[i].Path, err)
+		}
+	}
+
+	if len(modules) > 1 {
+		fmt.Fprintln(r.Out, "Maven modules:")
+		for _, module := range modules {
+			artifacts := strings.Join(module.Artifacts, ", ")
+			if artifacts == "" {
+				artifacts = "no artifacts"
+			}
+			fmt.Fprintf(r.Out, "  %s (%s): %s\n", module.ArtifactId, module.Path, artifacts)
+		}
+	}
+
+	return layersDir.Layer(buildMetadataLayer).WriteMetadata(BuildMetadata{Modules: modules})
+}
@@ -0,0 +1,222 @@
+package maven
+
+import (
+	"encoding/xml"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	repositoryEnvPattern = regexp.MustCompile("^MAVEN_REPOSITORY_(.+)_(URL|USERNAME|PASSWORD)$")
+	mirrorEnvPattern     = regexp.MustCompile("^MAVEN_MIRROR_(.+?)_(URL|MIRROR_OF|USERNAME|PASSWORD)$")
+)
+
+const envRepositoriesProfile = "heroku-env-repositories"
+
+type settingsXml struct {
+	XMLName        xml.Name          `xml:"settings"`
+	Xmlns          string            `xml:"xmlns,attr"`
+	Servers        []settingsServer  `xml:"servers>server,omitempty"`
+	Mirrors        []settingsMirror  `xml:"mirrors>mirror,omitempty"`
+	Proxies        []settingsProxy   `xml:"proxies>proxy,omitempty"`
+	Profiles       []settingsProfile `xml:"profiles>profile,omitempty"`
+	ActiveProfiles []string          `xml:"activeProfiles>activeProfile,omitempty"`
+}
+
+type settingsServer struct {
+	Id       string `xml:"id"`
+	Username string `xml:"username,omitempty"`
+	Password string `xml:"password,omitempty"`
+}
+
+type settingsMirror struct {
+	Id       string `xml:"id"`
+	Url      string `xml:"url"`
+	MirrorOf string `xml:"mirrorOf"`
+}
+
+type settingsProxy struct {
+	Id            string `xml:"id"`
+	Active        bool   `xml:"active"`
+	Protocol      string `xml:"protocol"`
+	Host          string `xml:"host"`
+	Port          string `xml:"port,omitempty"`
+	Username      string `xml:"username,omitempty"`
+	Password      string `xml:"password,omitempty"`
+	NonProxyHosts string `xml:"nonProxyHosts,omitempty"`
+}
+
+type settingsProfile struct {
+	Id                 string               `xml:"id"`
+	Repositories       []settingsRepository `xml:"repositories>repository,omitempty"`
+	PluginRepositories []settingsRepository `xml:"pluginRepositories>pluginRepository,omitempty"`
+}
+
+type settingsRepository struct {
+	Id  string `xml:"id"`
+	Url string `xml:"url"`
+}
+
+// settingsFromEnv builds Maven settings from MAVEN_REPOSITORY_<ID>_*, MAVEN_MIRROR_<ID>_* and the standard proxy
+// environment variables. The <ID> is lower-cased and underscores become dashes, so MAVEN_REPOSITORY_MY_CORP_URL
+// declares a repository with the id my-corp.
+func settingsFromEnv(environ []string) settingsXml {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	repositories := map[string]map[string]string{}
+	mirrors := map[string]map[string]string{}
+	for key, value := range env {
+		if m := repositoryEnvPattern.FindStringSubmatch(key); m != nil {
+			addSettingsField(repositories, m[1], m[2], value)
+		} else if m := mirrorEnvPattern.FindStringSubmatch(key); m != nil {
+			addSettingsField(mirrors, m[1], m[2], value)
+		}
+	}
+
+	settings := settingsXml{Xmlns: "http://maven.apache.org/SETTINGS/1.0.0"}
+
+	var profile settingsProfile
+	for _, id := range sortedKeys(repositories) {
+		fields := repositories[id]
+		if fields["URL"] == "" {
+			continue
+		}
+		repository := settingsRepository{Id: id, Url: fields["URL"]}
+		profile.Repositories = append(profile.Repositories, repository)
+		profile.PluginRepositories = append(profile.PluginRepositories, repository)
+		settings.Servers = appendServer(settings.Servers, id, fields)
+	}
+	if len(profile.Repositories) > 0 {
+		profile.Id = envRepositoriesProfile
+		settings.Profiles = []settingsProfile{profile}
+		settings.ActiveProfiles = []string{envRepositoriesProfile}
+	}
+
+	for _, id := range sortedKeys(mirrors) {
+		fields := mirrors[id]
+		if fields["URL"] == "" {
+			continue
+		}
+		mirrorOf := fields["MIRROR_OF"]
+		if mirrorOf == "" {
+			mirrorOf = "*"
+		}
+		settings.Mirrors = append(settings.Mirrors, settingsMirror{Id: id, Url: fields["URL"], MirrorOf: mirrorOf})
+		settings.Servers = appendServer(settings.Servers, id, fields)
+	}
+
+	nonProxyHosts := parseNoProxy(lookupEither(env, "NO_PROXY", "no_proxy"))
+	for _, protocol := range []string{"http", "https"} {
+		proxyUrl := lookupEither(env, strings.ToUpper(protocol)+"_PROXY", protocol+"_proxy")
+		if proxy, ok := parseProxy(protocol, proxyUrl); ok {
+			proxy.NonProxyHosts = nonProxyHosts
+			settings.Proxies = append(settings.Proxies, proxy)
+		}
+	}
+
+	return settings
+}
+
+func (s settingsXml) isEmpty() bool {
+	return len(s.Servers) == 0 && len(s.Mirrors) == 0 && len(s.Proxies) == 0 && len(s.Profiles) == 0
+}
+
+// hasRepositories is true when the settings change where artifacts come from, rather than only how they are fetched.
+func (s settingsXml) hasRepositories() bool {
+	return len(s.Mirrors) > 0 || len(s.Profiles) > 0
+}
+
+func marshalSettings(settings settingsXml) ([]byte, error) {
+	data, err := xml.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func addSettingsField(entries map[string]map[string]string, rawId, field, value string) {
+	id := strings.Replace(strings.ToLower(rawId), "_", "-", -1)
+	if entries[id] == nil {
+		entries[id] = map[string]string{}
+	}
+	entries[id][field] = value
+}
+
+func appendServer(servers []settingsServer, id string, fields map[string]string) []settingsServer {
+	if fields["USERNAME"] == "" && fields["PASSWORD"] == "" {
+		return servers
+	}
+	return append(servers, settingsServer{Id: id, Username: fields["USERNAME"], Password: fields["PASSWORD"]})
+}
+
+func parseProxy(protocol, rawUrl string) (settingsProxy, bool) {
+	if rawUrl == "" {
+		return settingsProxy{}, false
+	}
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "http://" + rawUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Hostname() == "" {
+		return settingsProxy{}, false
+	}
+
+	proxy := settingsProxy{
+		Id:       "env-" + protocol + "-proxy",
+		Active:   true,
+		Protocol: protocol,
+		Host:     u.Hostname(),
+		Port:     u.Port(),
+	}
+	if u.User != nil {
+		proxy.Username = u.User.Username()
+		proxy.Password, _ = u.User.Password()
+	}
+	return proxy, true
+}
+
+// parseNoProxy converts the comma separated NO_PROXY format into Maven's pipe separated nonProxyHosts.
+func parseNoProxy(noProxy string) string {
+	var hosts []string
+	for _, host := range strings.Split(noProxy, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.HasPrefix(host, ".") {
+			host = "*" + host
+		}
+		hosts = append(hosts, host)
+	}
+	return strings.Join(hosts, "|")
+}
+
+func lookupEither(env map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := env[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func sortedKeys(entries map[string]map[string]string) []string {
+	var keys []string
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,143 @@
+package maven
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const settingsDownloadAttempts = 3
+
+var settingsDownloadBackoff = time.Second
+
+var settingsHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected response %s", e.Status)
+}
+
+// downloadSettings fetches MAVEN_SETTINGS_URL, which the runner writes as a generated file since the settings can hold
+// repository credentials. The request uses MAVEN_SETTINGS_AUTH_TOKEN as a bearer token, or MAVEN_SETTINGS_USERNAME and
+// MAVEN_SETTINGS_PASSWORD for basic auth, and the response must match MAVEN_SETTINGS_SHA256 when it's set.
+func downloadSettings(settingsUrl string) ([]byte, error) {
+	data, err := fetchSettings(settingsUrl)
+	if err != nil {
+		return nil, failedToDownloadSettingsFromUrl(redactUrl(settingsUrl), err)
+	}
+
+	if expected, isSet := os.LookupEnv("MAVEN_SETTINGS_SHA256"); isSet {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+			return nil, invalidSettingsChecksum(expected, actual)
+		}
+	}
+
+	if err := validateSettingsXml(data); err != nil {
+		return nil, invalidSettingsXml(redactUrl(settingsUrl), err)
+	}
+	return data, nil
+}
+
+func fetchSettings(settingsUrl string) ([]byte, error) {
+	var err error
+	for attempt := 1; attempt <= settingsDownloadAttempts; attempt++ {
+		var data []byte
+		if data, err = fetchSettingsOnce(settingsUrl); err == nil {
+			return data, nil
+		} else if !isTransientError(err) {
+			return nil, err
+		}
+
+		if attempt < settingsDownloadAttempts {
+			time.Sleep(settingsDownloadBackoff * time.Duration(attempt))
+		}
+	}
+	return nil, err
+}
+
+func fetchSettingsOnce(settingsUrl string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, settingsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, isSet := os.LookupEnv("MAVEN_SETTINGS_AUTH_TOKEN"); isSet {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if username, isSet := os.LookupEnv("MAVEN_SETTINGS_USERNAME"); isSet {
+		req.SetBasicAuth(username, os.Getenv("MAVEN_SETTINGS_PASSWORD"))
+	}
+
+	resp, err := settingsHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// isTransientError is true for failures that are worth retrying: timeouts, temporary network errors, rate limiting and
+// server errors.
+func isTransientError(err error) bool {
+	if statusErr, ok := err.(*httpStatusError); ok {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	if netErr, ok := err.(net.Error); ok {
+		return netErr.Timeout() || netErr.Temporary()
+	}
+	return false
+}
+
+func validateSettingsXml(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+
+	if root != "settings" {
+		return errors.New("document does not have a <settings> root element")
+	}
+	return nil
+}
+
+// redactUrl hides any credentials embedded in the URL so that it's safe to print. A URL that can't be parsed is hidden
+// entirely, since there's no telling where its credentials are.
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "<redacted>"
+	} else if u.User == nil {
+		return rawUrl
+	}
+	u.User = url.User("xxxxx")
+	return u.String()
+}
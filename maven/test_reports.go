@@ -0,0 +1,231 @@
+package maven
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const testReportsLayer = "test-reports"
+
+var testReportDirs = []string{"surefire-reports", "failsafe-reports"}
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	XMLName   xml.Name   `xml:"testsuite"`
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Errors    int        `xml:"errors,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      string     `xml:"time,attr,omitempty"`
+	TestCases []testCase `xml:"testcase"`
+}
+
+type testCase struct {
+	Name      string       `xml:"name,attr"`
+	ClassName string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr,omitempty"`
+	Failure   *testProblem `xml:"failure"`
+	Error     *testProblem `xml:"error"`
+	Skipped   *testProblem `xml:"skipped"`
+}
+
+type testProblem struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// runTestsEnabled is true when MAVEN_RUN_TESTS opts in to running the app's tests during the build.
+func runTestsEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MAVEN_RUN_TESTS"))
+	return enabled
+}
+
+// withoutSkipTests removes the options that would prevent Surefire and Failsafe from running the tests.
+func withoutSkipTests(opts []string) []string {
+	var filtered []string
+	for _, opt := range opts {
+		switch opt {
+		case "-DskipTests", "-DskipTests=true", "-Dmaven.test.skip", "-Dmaven.test.skip=true":
+			continue
+		}
+		filtered = append(filtered, opt)
+	}
+	return filtered
+}
+
+// collectTestReports reads the Surefire and Failsafe XML reports of every module under appDir that were written since
+// the build started. Older reports were pushed with the app or restored from the cache by incremental builds, and can
+// belong to tests that no longer exist. The start is truncated to the second, for filesystems with coarse timestamps.
+func collectTestReports(appDir string, since time.Time) (testSuites, error) {
+	since = since.Truncate(time.Second)
+	var suites testSuites
+
+	err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != appDir {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !isTestReport(path) || info.ModTime().Before(since) {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var suite testSuite
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		suites.add(suite)
+		return nil
+	})
+
+	return suites, err
+}
+
+func isTestReport(path string) bool {
+	dir := filepath.Base(filepath.Dir(path))
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "TEST-") || filepath.Ext(name) != ".xml" {
+		return false
+	}
+	for _, reportDir := range testReportDirs {
+		if dir == reportDir && filepath.Base(filepath.Dir(filepath.Dir(path))) == "target" {
+			return true
+		}
+	}
+	return false
+}
+
+// add counts the test cases themselves, because not every plugin version writes all of the suite attributes.
+func (s *testSuites) add(suite testSuite) {
+	suite.Tests, suite.Failures, suite.Errors, suite.Skipped = len(suite.TestCases), 0, 0, 0
+	for _, tc := range suite.TestCases {
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+
+	s.Suites = append(s.Suites, suite)
+	s.Tests += suite.Tests
+	s.Failures += suite.Failures
+	s.Errors += suite.Errors
+	s.Skipped += suite.Skipped
+	if time, err := strconv.ParseFloat(suite.Time, 64); err == nil {
+		s.Time += time
+	}
+}
+
+func (s testSuites) failedTests() []string {
+	var failed []string
+	for _, suite := range s.Suites {
+		for _, tc := range suite.TestCases {
+			if tc.Failure != nil || tc.Error != nil {
+				failed = append(failed, fmt.Sprintf("%s.%s", tc.ClassName, tc.Name))
+			}
+		}
+	}
+	return failed
+}
+
+func (s testSuites) printSummary(out io.Writer) {
+	passed := s.Tests - s.Failures - s.Errors - s.Skipped
+	fmt.Fprintf(out, "Tests: %d passed, %d failed, %d errors, %d skipped (%d total)\n",
+		passed, s.Failures, s.Errors, s.Skipped, s.Tests)
+
+	failed := s.failedTests()
+	if len(failed) > maxFailureDetails {
+		failed = append(failed[:maxFailureDetails], fmt.Sprintf("... and %d more", len(failed)-maxFailureDetails))
+	}
+	for _, name := range failed {
+		fmt.Fprintf(out, "  FAILED %s\n", name)
+	}
+}
+
+// writeAggregateReport writes every suite into a single JUnit report in a launch layer, where CI can pick it up from
+// the image.
+func (s testSuites) writeAggregateReport(layersDir layers.Layers) (string, error) {
+	if err := removeTestReports(layersDir); err != nil {
+		return "", err
+	}
+	layer := layersDir.Layer(testReportsLayer)
+	if err := os.MkdirAll(layer.Root, 0755); err != nil {
+		return "", err
+	}
+
+	data, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	report := filepath.Join(layer.Root, "TEST-aggregate.xml")
+	if err := ioutil.WriteFile(report, append([]byte(xml.Header), data...), 0644); err != nil {
+		return "", err
+	}
+
+	return report, layer.WriteMetadata(struct {
+		Tests    int `toml:"tests"`
+		Failures int `toml:"failures"`
+		Errors   int `toml:"errors"`
+		Skipped  int `toml:"skipped"`
+	}{s.Tests, s.Failures, s.Errors, s.Skipped}, layers.Launch)
+}
+
+func (r *Runner) reportTests(appDir string, layersDir layers.Layers, since time.Time) error {
+	suites, err := collectTestReports(appDir, since)
+	if err != nil {
+		return err
+	}
+	if len(suites.Suites) == 0 {
+		fmt.Fprintln(r.Out, "No test reports found")
+		return removeTestReports(layersDir)
+	}
+
+	suites.printSummary(r.Out)
+
+	report, err := suites.writeAggregateReport(layersDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.Out, "Test report written to %s\n", report)
+	return nil
+}
+
+// removeTestReports removes the report of a previous build, so that the image doesn't ship results for tests that
+// didn't run.
+func removeTestReports(layersDir layers.Layers) error {
+	layer := layersDir.Layer(testReportsLayer)
+	if err := layer.RemoveMetadata(); err != nil {
+		return err
+	}
+	return os.RemoveAll(layer.Root)
+}
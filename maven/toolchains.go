@@ -0,0 +1,73 @@
+package maven
+
+import (
+	"encoding/xml"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/jdk"
+)
+
+type toolchainsXml struct {
+	XMLName    xml.Name    `xml:"toolchains"`
+	Xmlns      string      `xml:"xmlns,attr"`
+	Toolchains []toolchain `xml:"toolchain"`
+}
+
+type toolchain struct {
+	Type     string `xml:"type"`
+	Provides struct {
+		Id      string `xml:"id"`
+		Version string `xml:"version"`
+		Vendor  string `xml:"vendor"`
+	} `xml:"provides"`
+	Configuration struct {
+		JdkHome string `xml:"jdkHome"`
+	} `xml:"configuration"`
+}
+
+// constructToolchainsOpts points Maven at a toolchains.xml listing every JDK installed in the layers. It's passed
+// with -t rather than written to ~/.m2, which is the cached local repository, and only written by Run.
+func (r *Runner) constructToolchainsOpts(layersDir layers.Layers) ([]string, error) {
+	jdks, err := jdk.FindInstalledJdks(layersDir)
+	if err != nil {
+		return nil, failedToGenerateToolchains(err)
+	}
+	if len(jdks) == 0 {
+		return nil, nil
+	}
+
+	content, err := marshalToolchains(jdks)
+	if err != nil {
+		return nil, failedToGenerateToolchains(err)
+	}
+	return []string{"-t", r.generate(layersDir, "toolchains.xml", content)}, nil
+}
+
+func marshalToolchains(jdks []jdk.Jvm) ([]byte, error) {
+	toolchains := toolchainsXml{Xmlns: "http://maven.apache.org/TOOLCHAINS/1.1.0"}
+	for _, j := range jdks {
+		var t toolchain
+		t.Type = "jdk"
+		t.Provides.Id = "jdk-" + j.Version.Major
+		t.Provides.Version = toolchainVersion(j.Version.Major)
+		t.Provides.Vendor = j.Version.Vendor
+		t.Configuration.JdkHome = j.Home
+		toolchains.Toolchains = append(toolchains.Toolchains, t)
+	}
+
+	data, err := xml.MarshalIndent(toolchains, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// toolchainVersion uses the 1.x form for JDK 8 and older, which is what projects ask for in their toolchain
+// requirements (e.g. <version>1.8</version>).
+func toolchainVersion(major string) string {
+	switch major {
+	case "6", "7", "8":
+		return "1." + major
+	}
+	return major
+}
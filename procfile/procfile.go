@@ -1,36 +1,84 @@
 package procfile
 
 import (
+	"bufio"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/buildpack/libbuildpack/layers"
-	"gopkg.in/yaml.v2"
+	"github.com/heroku/java-buildpack/util"
 )
 
+var ErrNotFound = errors.New("could not find Procfile")
+
+// ParseError is a line of the Procfile that isn't a valid process type.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Procfile line %d: %s", e.Line, e.Err)
+}
+
+// Parse reads the Procfile, and returns ErrNotFound when there isn't one.
 func Parse(file string) (layers.Processes, error) {
-	if _, err := os.Stat(file); !os.IsNotExist(err) {
-		data, err := ioutil.ReadFile(file)
-		if err != nil {
-			return nil, errors.New("failed to read Procfile")
-		} else {
-			var processTypes map[string]string
-			err := yaml.Unmarshal(data, &processTypes)
-			if err != nil {
-				return nil, errors.New("failed to parse Procfile")
-			} else {
-				processes := layers.Processes{}
-				for name, command := range processTypes {
-					processes = append(processes, layers.Process{
-						Type:    name,
-						Command: command,
-					})
-				}
-
-				return processes, nil
-			}
+	fh, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return Read(fh)
+}
+
+// Read parses a Procfile, which has a "<type>: <command>" line for each process type, along with blank lines and
+// comments starting with #. The command is the rest of the line, so it can contain anything. Process types are
+// returned in the order they appear.
+func Read(r io.Reader) (layers.Processes, error) {
+	processes := layers.Processes{}
+	lines := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
 		}
+
+		separator := strings.Index(text, ":")
+		if separator < 0 {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("expected <type>: <command>, got %q", text)}
+		}
+		name, command := strings.TrimSpace(text[:separator]), strings.TrimSpace(text[separator+1:])
+
+		if !util.IsValidProcessType(name) {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("invalid process type %q, use only letters, digits, - and _", name)}
+		}
+		if command == "" {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("process type %s has no command", name)}
+		}
+		if previous, ok := lines[name]; ok {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("process type %s is already defined on line %d", name, previous)}
+		}
+		lines[name] = line
+
+		processes = append(processes, layers.Process{Type: name, Command: command})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(processes) == 0 {
+		return nil, &ParseError{Line: 1, Err: errors.New("no process types")}
 	}
-	return nil, errors.New("could not find Procfile")
+	return processes, nil
 }
@@ -3,6 +3,7 @@ package procfile_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/buildpack/libbuildpack/layers"
@@ -37,6 +38,62 @@ func testProcfile(t *testing.T, when spec.G, it spec.S) {
 				t.Fatal("Did not find a web process")
 			}
 		})
+
+		it("should report a missing Procfile", func() {
+			_, err := procfile.Parse(filepath.Join(fixture("app_with_pom"), "Procfile"))
+
+			if err != procfile.ErrNotFound {
+				t.Fatalf(`Expected ErrNotFound: got %v`, err)
+			}
+		})
+	})
+
+	when("#Read", func() {
+		it("should keep commands intact and process types in order", func() {
+			processes, err := procfile.Read(strings.NewReader("# processes\r\n" +
+				"worker: java -cp target/app.jar com.example.Worker --config={\"a\": 1}\r\n" +
+				"\r\n" +
+				"web:- java -jar target/app.jar\r\n" +
+				"release: [ -f target/release.sh ] && bash target/release.sh\r\n"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := layers.Processes{
+				{Type: "worker", Command: `java -cp target/app.jar com.example.Worker --config={"a": 1}`},
+				{Type: "web", Command: "- java -jar target/app.jar"},
+				{Type: "release", Command: "[ -f target/release.sh ] && bash target/release.sh"},
+			}
+			if len(processes) != len(expected) {
+				t.Fatalf(`Did not find process types: got %v, want %v`, processes, expected)
+			}
+			for i := range expected {
+				if processes[i] != expected[i] {
+					t.Fatalf(`Did not read process type: got %v, want %v`, processes[i], expected[i])
+				}
+			}
+		})
+
+		it("should report invalid lines", func() {
+			for procfileContents, expected := range map[string]string{
+				"web: java -jar app.jar\nweb: java -jar other.jar\n": "Procfile line 2: process type web is already defined on line 1",
+				"\n\nweb java -jar app.jar\n":                        `Procfile line 3: expected <type>: <command>, got "web java -jar app.jar"`,
+				"web.1: java -jar app.jar\n":                         `Procfile line 1: invalid process type "web.1", use only letters, digits, - and _`,
+				"web:\n":                                             "Procfile line 1: process type web has no command",
+				"# nothing to run\n":                                 "Procfile line 1: no process types",
+			} {
+				_, err := procfile.Read(strings.NewReader(procfileContents))
+
+				parseErr, ok := err.(*procfile.ParseError)
+				if !ok {
+					t.Fatalf(`Expected a ParseError for %q: got %v`, procfileContents, err)
+				}
+				if parseErr.Error() != expected {
+					t.Fatalf(`Error did not match: got %s, want %s`, parseErr.Error(), expected)
+				}
+			}
+		})
 	})
 }
 
@@ -0,0 +1,83 @@
+package sbom
+
+import "encoding/json"
+
+type cycloneDxBom struct {
+	BomFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDxMetadata    `json:"metadata"`
+	Components  []cycloneDxComponent `json:"components"`
+}
+
+type cycloneDxMetadata struct {
+	Tools     []cycloneDxTool    `json:"tools"`
+	Component cycloneDxComponent `json:"component"`
+}
+
+type cycloneDxTool struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+}
+
+type cycloneDxComponent struct {
+	BomRef             string              `json:"bom-ref,omitempty"`
+	Type               string              `json:"type"`
+	Group              string              `json:"group,omitempty"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	Scope              string              `json:"scope,omitempty"`
+	Purl               string              `json:"purl,omitempty"`
+	Hashes             []cycloneDxHash     `json:"hashes,omitempty"`
+	ExternalReferences []cycloneDxExternal `json:"externalReferences,omitempty"`
+}
+
+type cycloneDxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDxExternal struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+// CycloneDX formats the bill of materials as a CycloneDX 1.4 JSON document. The serial number and timestamp are
+// optional, and are left out so that the same build produces the same document.
+func (b Bom) CycloneDX() ([]byte, error) {
+	doc := cycloneDxBom{
+		BomFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDxMetadata{
+			Tools:     []cycloneDxTool{{Vendor: "Heroku", Name: "heroku/java"}},
+			Component: b.App.cycloneDx(),
+		},
+		Components: []cycloneDxComponent{},
+	}
+
+	for _, component := range b.Components {
+		doc.Components = append(doc.Components, component.cycloneDx())
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (c Component) cycloneDx() cycloneDxComponent {
+	component := cycloneDxComponent{
+		BomRef:  c.Purl,
+		Type:    c.Type,
+		Group:   c.Group,
+		Name:    c.Name,
+		Version: c.Version,
+		Scope:   c.Scope,
+		Purl:    c.Purl,
+	}
+	if c.Sha256 != "" {
+		component.Hashes = []cycloneDxHash{{Alg: "SHA-256", Content: c.Sha256}}
+	}
+	if c.Url != "" {
+		component.ExternalReferences = []cycloneDxExternal{{Type: "distribution", Url: c.Url}}
+	}
+	return component
+}
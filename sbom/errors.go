@@ -0,0 +1,28 @@
+package sbom
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	errorFmt = `
+%s
+  Caused by: %s
+
+We're sorry this build is failing! If you can't find the issue in application code,
+please submit a ticket so we can help: https://help.heroku.com/
+`
+)
+
+func errorWithCause(message string, cause error) error {
+	return errors.New(fmt.Sprintf(errorFmt, message, cause))
+}
+
+func unsupportedFormat(format string) error {
+	return fmt.Errorf("unsupported SBOM format %q, expected %s or %s", format, CycloneDX, SPDX)
+}
+
+func failedToWriteSbom(cause error) error {
+	return errorWithCause("Failed to write the software bill of materials", cause)
+}
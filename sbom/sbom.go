@@ -0,0 +1,245 @@
+package sbom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/jdk"
+	"github.com/heroku/java-buildpack/maven"
+)
+
+const (
+	sbomLayer = "sbom"
+
+	CycloneDX = "cyclonedx"
+	SPDX      = "spdx"
+
+	// scopes follow CycloneDX: excluded components were used to build the app, but are not part of the image
+	ScopeRequired = "required"
+	ScopeOptional = "optional"
+	ScopeExcluded = "excluded"
+)
+
+// Component is a piece of software that went into the image, or into building it.
+type Component struct {
+	// Type is a CycloneDX component type: platform for JVMs, application for build tools and library for dependencies
+	Type    string `toml:"type"`
+	Group   string `toml:"group,omitempty"`
+	Name    string `toml:"name"`
+	Version string `toml:"version"`
+	Scope   string `toml:"scope"`
+	Purl    string `toml:"purl"`
+	Url     string `toml:"url,omitempty"`
+	Sha256  string `toml:"sha256,omitempty"`
+}
+
+// Bom is the bill of materials for the app.
+type Bom struct {
+	App        Component   `toml:"app"`
+	Components []Component `toml:"components"`
+}
+
+// Collect builds the bill of materials from the metadata of the layers written by the JDK installer and the Maven
+// runner, so it doesn't need to look inside the installed JVMs or the app's jars.
+func Collect(appDir string, layersDir layers.Layers) (Bom, error) {
+	var bom Bom
+
+	jvms, err := collectJvms(layersDir)
+	if err != nil {
+		return bom, err
+	}
+	bom.Components = append(bom.Components, jvms...)
+
+	distribution, err := maven.FindDistribution(appDir, layersDir)
+	if err != nil {
+		return bom, err
+	}
+	if distribution.Version != "" {
+		bom.Components = append(bom.Components, Component{
+			Type:    "application",
+			Group:   "org.apache.maven",
+			Name:    "apache-maven",
+			Version: distribution.Version,
+			Scope:   ScopeExcluded,
+			Purl:    mavenPurl("org.apache.maven", "apache-maven", distribution.Version, "", ""),
+			Url:     distribution.Url,
+		})
+	}
+
+	buildMetadata, err := maven.ReadBuildMetadata(layersDir)
+	if err != nil {
+		return bom, err
+	}
+	for _, module := range buildMetadata.Modules {
+		if module.Path == "." {
+			bom.App = Component{
+				Type:    "application",
+				Group:   module.GroupId,
+				Name:    module.ArtifactId,
+				Version: module.Version,
+				Purl:    mavenPurl(module.GroupId, module.ArtifactId, module.Version, "", ""),
+			}
+		}
+	}
+	for _, dependency := range buildMetadata.Dependencies() {
+		bom.Components = append(bom.Components, dependencyComponent(dependency))
+	}
+
+	if bom.App.Name == "" {
+		bom.App = Component{Type: "application", Name: filepath.Base(appDir)}
+	}
+	return bom, nil
+}
+
+// collectJvms lists the installed JDK and JRE. When a JRE was extracted, it's the only JVM in the image and the JDK
+// was only used for the build.
+func collectJvms(layersDir layers.Layers) ([]Component, error) {
+	var jre, jdkMetadata jdk.Jvm
+	if err := layersDir.Layer("jre").ReadMetadata(&jre); err != nil {
+		return nil, err
+	}
+	if err := layersDir.Layer("jdk").ReadMetadata(&jdkMetadata); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	if jdkMetadata.Home != "" {
+		scope := ScopeRequired
+		if jre.Home != "" {
+			scope = ScopeExcluded
+		}
+		components = append(components, jvmComponent("jdk", jdkMetadata, scope))
+	}
+	if jre.Home != "" {
+		components = append(components, jvmComponent("jre", jre, ScopeRequired))
+	}
+	return components, nil
+}
+
+func jvmComponent(kind string, jvm jdk.Jvm, scope string) Component {
+	vendor := strings.TrimSuffix(jvm.Version.Vendor, "-")
+	name := fmt.Sprintf("%s-%s", vendor, kind)
+
+	qualifiers := url.Values{}
+	if jvm.Url != "" {
+		qualifiers.Set("download_url", jvm.Url)
+	}
+	if jvm.Sha256 != "" {
+		qualifiers.Set("checksum", "sha256:"+jvm.Sha256)
+	}
+	purl := fmt.Sprintf("pkg:generic/%s@%s", name, url.PathEscape(jvm.Version.Tag))
+	if len(qualifiers) > 0 {
+		purl += "?" + qualifiers.Encode()
+	}
+
+	return Component{
+		Type:    "platform",
+		Group:   vendor,
+		Name:    name,
+		Version: jvm.Version.Tag,
+		Scope:   scope,
+		Purl:    purl,
+		Url:     jvm.Url,
+		Sha256:  jvm.Sha256,
+	}
+}
+
+func dependencyComponent(dependency maven.Dependency) Component {
+	scope := ScopeExcluded
+	if dependency.IsRuntime() {
+		scope = ScopeRequired
+		if dependency.Optional {
+			scope = ScopeOptional
+		}
+	}
+
+	return Component{
+		Type:    "library",
+		Group:   dependency.GroupId,
+		Name:    dependency.ArtifactId,
+		Version: dependency.Version,
+		Scope:   scope,
+		Purl:    mavenPurl(dependency.GroupId, dependency.ArtifactId, dependency.Version, dependency.Type, dependency.Classifier),
+	}
+}
+
+// mavenPurl formats a package URL for a Maven artifact. The type qualifier is left out for jars, which is the default.
+func mavenPurl(groupId, artifactId, version, packaging, classifier string) string {
+	purl := fmt.Sprintf("pkg:maven/%s/%s@%s", url.PathEscape(groupId), url.PathEscape(artifactId), url.PathEscape(version))
+
+	qualifiers := url.Values{}
+	if classifier != "" {
+		qualifiers.Set("classifier", classifier)
+	}
+	if packaging != "" && packaging != "jar" {
+		qualifiers.Set("type", packaging)
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + qualifiers.Encode()
+	}
+	return purl
+}
+
+// Formats reads the formats to write from SBOM_FORMATS, a comma-separated list. CycloneDX is always written.
+func Formats() ([]string, error) {
+	formats := []string{CycloneDX}
+	for _, format := range strings.Split(os.Getenv("SBOM_FORMATS"), ",") {
+		switch format = strings.ToLower(strings.TrimSpace(format)); format {
+		case "", CycloneDX:
+		case SPDX:
+			formats = append(formats, SPDX)
+		default:
+			return nil, unsupportedFormat(format)
+		}
+	}
+	return formats, nil
+}
+
+// Write saves the bill of materials in each format into a launch layer, so that it ships with the image, and stores
+// the components in the layer metadata.
+func Write(bom Bom, formats []string, layersDir layers.Layers) ([]string, error) {
+	layer := layersDir.Layer(sbomLayer)
+	if err := os.RemoveAll(layer.Root); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(layer.Root, 0755); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, format := range formats {
+		var data []byte
+		var name string
+		var err error
+
+		switch format {
+		case CycloneDX:
+			name = "bom.cdx.json"
+			data, err = bom.CycloneDX()
+		case SPDX:
+			name = "bom.spdx.json"
+			data, err = bom.SPDX()
+		default:
+			err = unsupportedFormat(format)
+		}
+		if err != nil {
+			return nil, failedToWriteSbom(err)
+		}
+
+		file := filepath.Join(layer.Root, name)
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			return nil, failedToWriteSbom(err)
+		}
+		files = append(files, file)
+	}
+
+	if err := layer.WriteMetadata(bom, layers.Launch); err != nil {
+		return nil, failedToWriteSbom(err)
+	}
+	return files, nil
+}
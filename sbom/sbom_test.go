@@ -0,0 +1,212 @@
+package sbom_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
+	"github.com/heroku/java-buildpack/jdk"
+	"github.com/heroku/java-buildpack/maven"
+	"github.com/heroku/java-buildpack/sbom"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSbom(t *testing.T) {
+	spec.Run(t, "SBOM", testSbom, spec.Report(report.Terminal{}))
+}
+
+func testSbom(t *testing.T, when spec.G, it spec.S) {
+	var layersDir layers.Layers
+
+	it.Before(func() {
+		root, err := ioutil.TempDir("", "layers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		layersDir = layers.NewLayers(root, logger.DefaultLogger())
+
+		version, _ := jdk.ParseVersionString("8")
+		jdkMetadata := jdk.Jvm{
+			Home:    layersDir.Layer("jdk").Root,
+			Version: version,
+			Url:     "https://lang-jvm.s3.amazonaws.com/jdk/heroku-18/openjdk1.8.0_212.tar.gz",
+			Sha256:  "0123456789abcdef",
+		}
+		if err := layersDir.Layer("jdk").WriteMetadata(jdkMetadata, layers.Build, layers.Cache); err != nil {
+			t.Fatal(err)
+		}
+		jreMetadata := jdkMetadata
+		jreMetadata.Home = layersDir.Layer("jre").Root
+		if err := layersDir.Layer("jre").WriteMetadata(jreMetadata, layers.Launch); err != nil {
+			t.Fatal(err)
+		}
+
+		buildMetadata := maven.BuildMetadata{Modules: []maven.Module{{
+			Path:       ".",
+			GroupId:    "com.mycompany.app",
+			ArtifactId: "my-app",
+			Version:    "1.0-SNAPSHOT",
+			Dependencies: []maven.Dependency{
+				{GroupId: "org.slf4j", ArtifactId: "slf4j-api", Type: "jar", Version: "1.7.25", Scope: "compile"},
+				{GroupId: "junit", ArtifactId: "junit", Type: "jar", Version: "4.12", Scope: "test"},
+			},
+		}}}
+		if err := layersDir.Layer("maven_build").WriteMetadata(buildMetadata); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(layersDir.Root)
+		os.Unsetenv("SBOM_FORMATS")
+	})
+
+	when("#Collect", func() {
+		it("should list the JVMs, Maven and the dependencies", func() {
+			bom, err := sbom.Collect(fixture("app_with_wrapper"), layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bom.App.Name != "my-app" || bom.App.Version != "1.0-SNAPSHOT" {
+				t.Fatalf(`Did not describe the app: %v`, bom.App)
+			}
+
+			expected := map[string]string{
+				"openjdk-jdk":  sbom.ScopeExcluded,
+				"openjdk-jre":  sbom.ScopeRequired,
+				"apache-maven": sbom.ScopeExcluded,
+				"slf4j-api":    sbom.ScopeRequired,
+				"junit":        sbom.ScopeExcluded,
+			}
+			if len(bom.Components) != len(expected) {
+				t.Fatalf(`Did not find every component: got %d, want %d`, len(bom.Components), len(expected))
+			}
+			for _, component := range bom.Components {
+				if scope, ok := expected[component.Name]; !ok || component.Scope != scope {
+					t.Fatalf(`Unexpected component: %v`, component)
+				}
+				if component.Name == "apache-maven" && component.Version != "3.5.3" {
+					t.Fatalf(`Did not find the Maven version: got %s, want %s`, component.Version, "3.5.3")
+				}
+				if component.Name == "openjdk-jre" && !strings.Contains(component.Purl, "checksum=sha256%3A0123456789abcdef") {
+					t.Fatalf(`Did not include the JRE checksum: %s`, component.Purl)
+				}
+				if component.Name == "slf4j-api" && component.Purl != "pkg:maven/org.slf4j/slf4j-api@1.7.25" {
+					t.Fatalf(`Did not create the package URL: %s`, component.Purl)
+				}
+			}
+		})
+	})
+
+	when("#Write", func() {
+		it("should write a CycloneDX document into a launch layer", func() {
+			bom, err := sbom.Collect(fixture("app_with_wrapper"), layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			formats, err := sbom.Formats()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := sbom.Write(bom, formats, layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 1 || filepath.Base(files[0]) != "bom.cdx.json" {
+				t.Fatalf(`Did not write the CycloneDX document: %v`, files)
+			}
+
+			var doc struct {
+				BomFormat  string `json:"bomFormat"`
+				Components []struct {
+					Name   string `json:"name"`
+					Hashes []struct {
+						Content string `json:"content"`
+					} `json:"hashes"`
+				} `json:"components"`
+			}
+			data, err := ioutil.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.BomFormat != "CycloneDX" || len(doc.Components) != 5 {
+				t.Fatalf(`Did not write the components: %s`, data)
+			}
+			if len(doc.Components[0].Hashes) != 1 || doc.Components[0].Hashes[0].Content != "0123456789abcdef" {
+				t.Fatalf(`Did not write the JDK checksum: %s`, data)
+			}
+
+			var metadata sbom.Bom
+			if err := layersDir.Layer("sbom").ReadMetadata(&metadata); err != nil {
+				t.Fatal(err)
+			}
+			if len(metadata.Components) != 5 {
+				t.Fatalf(`Did not write the components to the layer metadata: got %d, want %d`, len(metadata.Components), 5)
+			}
+		})
+
+		it("should also write an SPDX document when asked to", func() {
+			os.Setenv("SBOM_FORMATS", "cyclonedx,spdx")
+
+			bom, err := sbom.Collect(fixture("app_with_wrapper"), layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			formats, err := sbom.Formats()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := sbom.Write(bom, formats, layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 2 || filepath.Base(files[1]) != "bom.spdx.json" {
+				t.Fatalf(`Did not write the SPDX document: %v`, files)
+			}
+
+			var doc struct {
+				SpdxVersion string        `json:"spdxVersion"`
+				Packages    []interface{} `json:"packages"`
+			}
+			data, err := ioutil.ReadFile(files[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.SpdxVersion != "SPDX-2.3" || len(doc.Packages) != 6 {
+				t.Fatalf(`Did not write the packages: %s`, data)
+			}
+		})
+
+		it("should reject unknown formats", func() {
+			os.Setenv("SBOM_FORMATS", "swid")
+
+			if _, err := sbom.Formats(); err == nil {
+				t.Fatal("Expected an error for an unknown format")
+			}
+		})
+	})
+}
+
+func fixture(name string) string {
+	wd, _ := os.Getwd()
+	return filepath.Join(wd, "..", "test", "fixtures", name)
+}
@@ -0,0 +1,109 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type spdxDocument struct {
+	SpdxVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SpdxId            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Packages          []spdxPackage    `json:"packages"`
+	Relationships     []spdxRelation   `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SpdxId           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelation struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX formats the bill of materials as an SPDX 2.3 JSON document. Unlike CycloneDX, SPDX requires a creation time
+// and a unique namespace, which is derived from the components.
+func (b Bom) SPDX() ([]byte, error) {
+	doc := spdxDocument{
+		SpdxVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SpdxId:      "SPDXRef-DOCUMENT",
+		Name:        b.App.Name,
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Organization: Heroku", "Tool: heroku/java"},
+		},
+		Packages: []spdxPackage{b.App.spdx("SPDXRef-App")},
+		Relationships: []spdxRelation{
+			{SpdxElementId: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSpdxElement: "SPDXRef-App"},
+		},
+	}
+
+	hash := sha256.New()
+	for i, component := range b.Components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		doc.Packages = append(doc.Packages, component.spdx(id))
+
+		if component.Scope == ScopeExcluded {
+			doc.Relationships = append(doc.Relationships, spdxRelation{SpdxElementId: id, RelationshipType: "BUILD_DEPENDENCY_OF", RelatedSpdxElement: "SPDXRef-App"})
+		} else {
+			doc.Relationships = append(doc.Relationships, spdxRelation{SpdxElementId: "SPDXRef-App", RelationshipType: "DEPENDS_ON", RelatedSpdxElement: id})
+		}
+		fmt.Fprintln(hash, component.Purl)
+	}
+	doc.DocumentNamespace = fmt.Sprintf("https://heroku.com/spdxdocs/%s-%x", b.App.Name, hash.Sum(nil)[:8])
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (c Component) spdx(id string) spdxPackage {
+	pkg := spdxPackage{
+		SpdxId:           id,
+		Name:             c.Name,
+		VersionInfo:      c.Version,
+		DownloadLocation: "NOASSERTION",
+	}
+	if c.Group != "" {
+		pkg.Supplier = "Organization: " + c.Group
+	}
+	if c.Url != "" {
+		pkg.DownloadLocation = c.Url
+	}
+	if c.Sha256 != "" {
+		pkg.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.Sha256}}
+	}
+	if c.Purl != "" {
+		pkg.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: c.Purl}}
+	}
+	return pkg
+}
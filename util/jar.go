@@ -1,57 +1,199 @@
 package util
 
 import (
-	"archive/zip"
 	"errors"
 	"fmt"
-	"github.com/buildpack/libbuildpack/layers"
-	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
 )
 
+// nonExecutableSuffixes are the classifiers of artifacts that are never meant to be run.
+var nonExecutableSuffixes = []string{"-sources", "-javadoc", "-tests", "-test-sources", "-test-javadoc", "-plain"}
+
+// JarCandidate is a jar or war found in a target directory, along with what its manifest says about it.
+type JarCandidate struct {
+	// Path is relative to the app directory
+	Path              string
+	Manifest          Manifest
+	ClassPathComplete bool
+	Size              int64
+	// Skipped explains why the candidate can't be run, and is empty for executable jars
+	Skipped string
+}
+
 func FindExecutableJar(appDir string) (layers.Processes, error) {
-	if jars, err := filepath.Glob(filepath.Join(appDir, "target", "*.[jw]ar")); err == nil {
-		for _, jar := range jars {
-			return detectMainClass(jar)
+	return FindExecutableJarInModule(appDir, ".")
+}
+
+// FindExecutableJarInModule looks for a jar in the target directory of a Maven module, whose path is relative to the
+// app directory. Commands refer to the jar relative to the app directory.
+func FindExecutableJarInModule(appDir, module string) (layers.Processes, error) {
+	return SelectExecutableJar(appDir, module, logger.Logger{})
+}
+
+// SelectExecutableJar inspects every jar and war in the target directory of a module, picks the best one to run, and
+// logs why.
+func SelectExecutableJar(appDir, module string, log logger.Logger) (layers.Processes, error) {
+	candidate, err := SelectJar(appDir, module, log)
+	if err == errNoExecutableJar {
+		return layers.Processes{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return layers.Processes{candidate.Process()}, nil
+}
+
+var errNoExecutableJar = errors.New("could not find an executable Jar file")
+
+// noMainClass is why plain wars are skipped, since they need a servlet container to run.
+const noMainClass = "no Main-Class in the manifest"
+
+// SelectJar returns the best executable jar in the target directory of a module.
+func SelectJar(appDir, module string, log logger.Logger) (JarCandidate, error) {
+	candidates, err := RankJarCandidates(appDir, module)
+	if err != nil {
+		return JarCandidate{}, err
+	}
+	if len(candidates) == 0 {
+		return JarCandidate{}, errors.New("could not find a Jar file")
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Skipped != "" {
+			log.Debug("Skipping %s: %s", candidate.Path, candidate.Skipped)
 		}
 	}
 
-	return nil, errors.New("could not find a Jar file")
+	best := candidates[0]
+	if best.Skipped != "" {
+		return JarCandidate{}, errNoExecutableJar
+	}
+	if len(candidates) > 1 {
+		log.Info("Selected %s (%s) out of %d candidates", best.Path, best.describe(), len(candidates))
+	}
+	return best, nil
 }
 
-func detectMainClass(jar string) (layers.Processes, error) {
-	reader, err := zip.OpenReader(jar)
+// FindWar returns the war in the target directory of a module that has no Main-Class, and so must be deployed to a
+// servlet container. Wars with a classifier that is never deployed, such as sources, are ignored.
+func FindWar(appDir, module string) (JarCandidate, error) {
+	candidates, err := RankJarCandidates(appDir, module)
 	if err != nil {
-		return nil, errors.New("unable to open Jar file")
-	} else {
-		for _, file := range reader.File {
-			if file.Name == "META-INF/MANIFEST.MF" {
-				fileReader, err := file.Open()
-				if err == nil {
-					bytes, err := ioutil.ReadAll(fileReader)
-					if err != nil {
-						return nil, errors.New("unable to read Jar file")
-					} else {
-						if strings.Contains(string(bytes), "Main-Class") {
-							command := "java"
-							if strings.Contains(string(bytes), "Start-Class") {
-								command = fmt.Sprintf("%s -Dserver.port=$PORT", command)
-							}
-							command = fmt.Sprintf("%s -jar target/%s", command, filepath.Base(jar))
-
-							webProcess := layers.Process{
-								Type:    "web",
-								Command: command,
-							}
-
-							return layers.Processes{webProcess}, nil
-						}
-					}
-				}
-				defer fileReader.Close()
-			}
+		return JarCandidate{}, err
+	}
+
+	for _, candidate := range candidates {
+		if filepath.Ext(candidate.Path) == ".war" && candidate.Skipped == noMainClass {
+			return candidate, nil
 		}
 	}
-	return layers.Processes{}, nil
-}
\ No newline at end of file
+	return JarCandidate{}, errors.New("could not find a War file")
+}
+
+// Process runs the jar with java -jar.
+func (c JarCandidate) Process() layers.Process {
+	command := "java"
+	if c.Manifest.StartClass != "" {
+		command = fmt.Sprintf("%s -Dserver.port=$PORT", command)
+	}
+	command = fmt.Sprintf("%s -jar %s", command, c.Path)
+
+	return layers.Process{Type: "web", Command: command}
+}
+
+// RankJarCandidates lists the jars and wars in the target directory of a module, best first. Executable jars come
+// before the others, and are ranked by Start-Class (a Spring Boot jar), a complete Class-Path, and size, since a jar
+// with its dependencies is bigger than the plain one. Ties are broken by name so that the choice doesn't depend on the
+// order of the directory.
+func RankJarCandidates(appDir, module string) ([]JarCandidate, error) {
+	jars, err := filepath.Glob(filepath.Join(appDir, module, "target", "*.[jw]ar"))
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []JarCandidate
+	for _, jar := range jars {
+		candidate, err := inspectJar(appDir, jar)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case (a.Skipped == "") != (b.Skipped == ""):
+			return a.Skipped == ""
+		case (a.Manifest.StartClass != "") != (b.Manifest.StartClass != ""):
+			return a.Manifest.StartClass != ""
+		case a.ClassPathComplete != b.ClassPathComplete:
+			return a.ClassPathComplete
+		case a.Size != b.Size:
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+	return candidates, nil
+}
+
+func inspectJar(appDir, jar string) (JarCandidate, error) {
+	relJar, err := filepath.Rel(appDir, jar)
+	if err != nil {
+		return JarCandidate{}, err
+	}
+	candidate := JarCandidate{Path: relJar, ClassPathComplete: true}
+
+	if info, err := os.Stat(jar); err == nil {
+		candidate.Size = info.Size()
+	}
+
+	name := strings.TrimSuffix(filepath.Base(jar), filepath.Ext(jar))
+	if strings.HasPrefix(name, "original-") {
+		// the maven-shade-plugin keeps the jar it replaced under this name
+		candidate.Skipped = "original jar replaced by the shade plugin"
+		return candidate, nil
+	}
+	for _, suffix := range nonExecutableSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			candidate.Skipped = fmt.Sprintf("%s jars are not executable", strings.TrimPrefix(suffix, "-"))
+			return candidate, nil
+		}
+	}
+
+	manifest, err := ReadJarManifest(jar)
+	if err != nil {
+		candidate.Skipped = err.Error()
+		return candidate, nil
+	}
+
+	candidate.Manifest = manifest
+	if manifest.MainClass == "" {
+		candidate.Skipped = noMainClass
+		return candidate, nil
+	}
+
+	for _, entry := range manifest.ClassPath {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(jar), filepath.FromSlash(entry))); err != nil {
+			candidate.ClassPathComplete = false
+			break
+		}
+	}
+	return candidate, nil
+}
+
+func (c JarCandidate) describe() string {
+	reasons := []string{"Main-Class " + c.Manifest.MainClass}
+	if c.Manifest.StartClass != "" {
+		reasons = append(reasons, "Start-Class "+c.Manifest.StartClass)
+	}
+	if !c.ClassPathComplete {
+		reasons = append(reasons, "incomplete Class-Path")
+	}
+	return strings.Join(append(reasons, fmt.Sprintf("%d bytes", c.Size)), ", ")
+}
@@ -37,6 +37,23 @@ func testJar(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should find an executable jar in a module", func() {
+			processes, err := util.FindExecutableJarInModule(fixture("app_with_modules"), "service")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(processes) != 1 {
+				t.Fatalf(`Did not find executable JAR: got %d, want %d`, len(processes), 1)
+			}
+
+			expected := "java -jar service/target/service-1.0-SNAPSHOT.jar"
+			if processes[0].Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, processes[0].Command, expected)
+			}
+		})
+
 		it("should find an executable war", func() {
 			processes, err := util.FindExecutableJar(fixture("app_with_exec_war"))
 
@@ -57,6 +74,73 @@ func testJar(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf(`Did not create correct command: got %s, want %s`, processes[0].Command, expected)
 			}
 		})
+
+		it("should pick the best of several jars", func() {
+			processes, err := util.FindExecutableJar(fixture("app_with_many_jars"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(processes) != 1 {
+				t.Fatalf(`Did not find executable JAR: got %d, want %d`, len(processes), 1)
+			}
+
+			expected := "java -jar target/app-1.0-jar-with-dependencies.jar"
+			if processes[0].Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, processes[0].Command, expected)
+			}
+		})
+	})
+
+	when("#FindWar", func() {
+		it("should find a war without a Main-Class", func() {
+			candidate, err := util.FindWar(fixture("app_with_war"), ".")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if candidate.Path != "target/demo-1.0.war" {
+				t.Fatalf(`Did not find the war: got %s, want %s`, candidate.Path, "target/demo-1.0.war")
+			}
+		})
+
+		it("should not find executable wars", func() {
+			if _, err := util.FindWar(fixture("app_with_exec_war"), "."); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	})
+
+	when("#RankJarCandidates", func() {
+		it("should skip the jars that can't be run", func() {
+			candidates, err := util.RankJarCandidates(fixture("app_with_many_jars"), ".")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := map[string]bool{
+				"target/app-1.0-jar-with-dependencies.jar": false,
+				"target/app-1.0.jar":                       false,
+				"target/app-1.0-sources.jar":               true,
+				"target/app-1.0-tests.jar":                 true,
+				"target/original-app-1.0.jar":              true,
+			}
+			if len(candidates) != len(expected) {
+				t.Fatalf(`Did not find every candidate: got %d, want %d`, len(candidates), len(expected))
+			}
+			for _, candidate := range candidates {
+				if skipped := candidate.Skipped != ""; skipped != expected[candidate.Path] {
+					t.Fatalf(`Unexpected candidate %s: skipped %q`, candidate.Path, candidate.Skipped)
+				}
+			}
+
+			if candidates[1].Path != "target/app-1.0.jar" || candidates[1].ClassPathComplete {
+				t.Fatalf(`Did not rank the jar with an incomplete Class-Path second: %v`, candidates[1])
+			}
+		})
 	})
 }
 
@@ -0,0 +1,183 @@
+package util
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const manifestPath = "META-INF/MANIFEST.MF"
+
+// Attributes are the headers of a manifest section. Header names are case-insensitive, so they are stored in lower
+// case and should be looked up with Get.
+type Attributes map[string]string
+
+func (a Attributes) Get(name string) string {
+	return a[strings.ToLower(name)]
+}
+
+// Manifest is a parsed META-INF/MANIFEST.MF, with the attributes of the main section that matter for launching the
+// app read into fields.
+type Manifest struct {
+	MainClass             string
+	StartClass            string
+	ClassPath             []string
+	SpringBootVersion     string
+	ImplementationVersion string
+	LauncherAgentClass    string
+
+	// Main holds every attribute of the main section
+	Main Attributes
+	// Entries holds the per-entry sections, by the value of their Name attribute
+	Entries map[string]Attributes
+}
+
+type ManifestError struct {
+	Line int
+	Err  error
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("manifest line %d: %s", e.Line, e.Err)
+}
+
+// ReadManifest parses a manifest following the JAR file specification: sections are separated by blank lines, and
+// lines longer than 72 bytes continue on the next line, which starts with a single space.
+func ReadManifest(r io.Reader) (Manifest, error) {
+	manifest := Manifest{Main: Attributes{}, Entries: map[string]Attributes{}}
+
+	type section struct {
+		attributes Attributes
+		line       int
+	}
+	sections := []section{{attributes: manifest.Main, line: 1}}
+	current := sections[0]
+
+	var name, value string
+	flush := func() {
+		if name != "" {
+			current.attributes[strings.ToLower(name)] = value
+			name, value = "", ""
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanManifestLines)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		switch {
+		case text == "":
+			flush()
+			if len(current.attributes) > 0 {
+				current = section{attributes: Attributes{}, line: line + 1}
+				sections = append(sections, current)
+			}
+		case strings.HasPrefix(text, " "):
+			if name == "" {
+				return manifest, &ManifestError{Line: line, Err: errors.New("continuation line without a header")}
+			}
+			value += text[1:]
+		default:
+			flush()
+			separator := strings.Index(text, ": ")
+			if separator < 0 && strings.HasSuffix(text, ":") {
+				separator = len(text) - 1
+			}
+			if separator <= 0 || !isManifestHeaderName(text[:separator]) {
+				return manifest, &ManifestError{Line: line, Err: fmt.Errorf("invalid header %q", text)}
+			}
+			name = text[:separator]
+			value = strings.TrimPrefix(text[separator+1:], " ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return manifest, err
+	}
+	flush()
+
+	for _, entry := range sections[1:] {
+		if len(entry.attributes) == 0 {
+			continue
+		}
+		entryName := entry.attributes.Get("Name")
+		if entryName == "" {
+			return manifest, &ManifestError{Line: entry.line, Err: errors.New("entry section without a Name")}
+		}
+		manifest.Entries[entryName] = entry.attributes
+	}
+
+	manifest.MainClass = strings.TrimSpace(manifest.Main.Get("Main-Class"))
+	manifest.StartClass = strings.TrimSpace(manifest.Main.Get("Start-Class"))
+	manifest.ClassPath = strings.Fields(manifest.Main.Get("Class-Path"))
+	manifest.SpringBootVersion = strings.TrimSpace(manifest.Main.Get("Spring-Boot-Version"))
+	manifest.ImplementationVersion = strings.TrimSpace(manifest.Main.Get("Implementation-Version"))
+	manifest.LauncherAgentClass = strings.TrimSpace(manifest.Main.Get("Launcher-Agent-Class"))
+	return manifest, nil
+}
+
+// ReadJarManifest reads the manifest of a jar or war.
+func ReadJarManifest(jar string) (Manifest, error) {
+	reader, err := zip.OpenReader(jar)
+	if err != nil {
+		return Manifest{}, errors.New("unable to open Jar file")
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name == manifestPath {
+			fileReader, err := file.Open()
+			if err != nil {
+				return Manifest{}, errors.New("unable to read Jar file")
+			}
+			defer fileReader.Close()
+
+			return ReadManifest(fileReader)
+		}
+	}
+	return Manifest{}, errors.New("no manifest")
+}
+
+// isManifestHeaderName checks the header name grammar: alphanumerics, dashes and underscores.
+func isManifestHeaderName(name string) bool {
+	return isAlphanumericName(name)
+}
+
+// isAlphanumericName is true for a non-empty name made of letters, digits, dashes and underscores.
+func isAlphanumericName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// scanManifestLines splits lines ending with CR LF, LF or CR, all of which are allowed in manifests.
+func scanManifestLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// wait for the next byte, which might be the LF of a CR LF
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,101 @@
+package util_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestManifest(t *testing.T) {
+	spec.Run(t, "Manifest", testManifest, spec.Report(report.Terminal{}))
+}
+
+func testManifest(t *testing.T, when spec.G, it spec.S) {
+	when("#ReadManifest", func() {
+		it("should read the main attributes", func() {
+			manifest, err := util.ReadManifest(strings.NewReader("Manifest-Version: 1.0\r\n" +
+				"main-class: com.example.Main\r\n" +
+				"Class-Path: lib/commons-lang3-3.8.1.jar lib/a-very-long-dependency-name-that-wr\r\n" +
+				" aps-1.0.jar\r\n" +
+				"Spring-Boot-Version: 2.1.4.RELEASE\r\n" +
+				"Implementation-Version: 1.0\r\n" +
+				"Launcher-Agent-Class: com.example.Agent\r\n\r\n"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if manifest.MainClass != "com.example.Main" {
+				t.Fatalf(`Did not read the Main-Class: got %s, want %s`, manifest.MainClass, "com.example.Main")
+			}
+
+			expected := []string{"lib/commons-lang3-3.8.1.jar", "lib/a-very-long-dependency-name-that-wraps-1.0.jar"}
+			if strings.Join(manifest.ClassPath, " ") != strings.Join(expected, " ") {
+				t.Fatalf(`Did not join the continuation line: got %v, want %v`, manifest.ClassPath, expected)
+			}
+
+			if manifest.SpringBootVersion != "2.1.4.RELEASE" || manifest.ImplementationVersion != "1.0" || manifest.LauncherAgentClass != "com.example.Agent" {
+				t.Fatalf(`Did not read the attributes: %v`, manifest)
+			}
+
+			if manifest.Main.Get("MANIFEST-VERSION") != "1.0" {
+				t.Fatal("Did not look up the attribute regardless of case")
+			}
+		})
+
+		it("should not mistake similar headers for the Main-Class", func() {
+			manifest, err := util.ReadManifest(strings.NewReader("Manifest-Version: 1.0\n" +
+				"X-Main-Class-Foo: com.example.Foo\n" +
+				"Description: the Main-Class: is elsewhere\n"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if manifest.MainClass != "" {
+				t.Fatalf(`Did not expect a Main-Class: got %s`, manifest.MainClass)
+			}
+		})
+
+		it("should read the per-entry sections", func() {
+			manifest, err := util.ReadManifest(strings.NewReader("Manifest-Version: 1.0\r" +
+				"Main-Class: com.example.Main\r\r" +
+				"Name: com/example/\r" +
+				"Sealed: true\r\r" +
+				"Name: com/example/Main.class\r" +
+				"SHA-256-Digest: abc=\r\r"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(manifest.Entries) != 2 {
+				t.Fatalf(`Did not read every entry: got %d, want %d`, len(manifest.Entries), 2)
+			}
+
+			if manifest.Entries["com/example/"].Get("sealed") != "true" {
+				t.Fatalf(`Did not read the entry attributes: %v`, manifest.Entries["com/example/"])
+			}
+
+			if manifest.Main.Get("Sealed") != "" {
+				t.Fatal("Did not keep the entry attributes out of the main section")
+			}
+		})
+
+		it("should report invalid lines", func() {
+			_, err := util.ReadManifest(strings.NewReader("Manifest-Version: 1.0\nnot a header\n"))
+
+			manifestErr, ok := err.(*util.ManifestError)
+			if !ok {
+				t.Fatalf(`Expected a ManifestError: got %v`, err)
+			}
+
+			if manifestErr.Line != 2 {
+				t.Fatalf(`Did not report the line: got %d, want %d`, manifestErr.Line, 2)
+			}
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Pom struct {
+	GroupId    string
+	ArtifactId string
+	Version    string
+	Packaging  string
+	Properties Properties
+	Modules    []string
+	// Dependencies are the dependencies declared in the pom itself, without the ones it inherits
+	Dependencies []PomDependency
+}
+
+type PomDependency struct {
+	GroupId    string
+	ArtifactId string
+}
+
+type pomXml struct {
+	GroupId      string   `xml:"groupId"`
+	ArtifactId   string   `xml:"artifactId"`
+	Version      string   `xml:"version"`
+	Packaging    string   `xml:"packaging"`
+	Modules      []string `xml:"modules>module"`
+	Dependencies []struct {
+		GroupId    string `xml:"groupId"`
+		ArtifactId string `xml:"artifactId"`
+	} `xml:"dependencies>dependency"`
+	Parent struct {
+		GroupId string `xml:"groupId"`
+		Version string `xml:"version"`
+	} `xml:"parent"`
+	Properties struct {
+		Entries []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"properties"`
+}
+
+var pomPropertyRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+func ReadPomFile(filename string) (Pom, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Pom{}, err
+	}
+	defer file.Close()
+
+	var raw pomXml
+	if err := xml.NewDecoder(file).Decode(&raw); err != nil {
+		return Pom{}, err
+	}
+
+	pom := Pom{
+		GroupId:    raw.GroupId,
+		ArtifactId: raw.ArtifactId,
+		Version:    raw.Version,
+		Packaging:  raw.Packaging,
+		Properties: Properties{},
+		Modules:    raw.Modules,
+	}
+
+	// groupId and version are inherited from the parent when they are not declared
+	if pom.GroupId == "" {
+		pom.GroupId = raw.Parent.GroupId
+	}
+	if pom.Version == "" {
+		pom.Version = raw.Parent.Version
+	}
+	if pom.Packaging == "" {
+		pom.Packaging = "jar"
+	}
+
+	for _, entry := range raw.Properties.Entries {
+		pom.Properties[entry.XMLName.Local] = strings.TrimSpace(entry.Value)
+	}
+
+	for _, dependency := range raw.Dependencies {
+		pom.Dependencies = append(pom.Dependencies, PomDependency{
+			GroupId:    pom.interpolate(strings.TrimSpace(dependency.GroupId), map[string]bool{}),
+			ArtifactId: pom.interpolate(strings.TrimSpace(dependency.ArtifactId), map[string]bool{}),
+		})
+	}
+
+	return pom, nil
+}
+
+// Property looks up a property declared in the pom, expanding any ${...} references to other properties or to the
+// project coordinates. References that can't be resolved are left in place.
+func (p Pom) Property(name string) (string, bool) {
+	value, ok := p.Properties[name]
+	if !ok {
+		return "", false
+	}
+	return p.interpolate(value, map[string]bool{name: true}), true
+}
+
+func (p Pom) interpolate(value string, seen map[string]bool) string {
+	return pomPropertyRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := pomPropertyRef.FindStringSubmatch(ref)[1]
+		switch name {
+		case "project.groupId", "pom.groupId":
+			return p.GroupId
+		case "project.artifactId", "pom.artifactId":
+			return p.ArtifactId
+		case "project.version", "pom.version":
+			return p.Version
+		}
+
+		if seen[name] {
+			return ref
+		}
+		if v, ok := p.Properties[name]; ok {
+			seen[name] = true
+			defer delete(seen, name)
+			return p.interpolate(v, seen)
+		}
+		return ref
+	})
+}
+
+// PomProperties are the coordinates that the Maven Archiver writes to pom.properties when it packages an artifact.
+type PomProperties struct {
+	GroupId    string
+	ArtifactId string
+	Version    string
+}
+
+// ReadPomProperties reads the pom.properties that the Maven Archiver leaves in the target directory of a module.
+func ReadPomProperties(appDir, module string) (PomProperties, error) {
+	props, err := ReadPropertiesFile(filepath.Join(appDir, module, "target", "maven-archiver", "pom.properties"))
+	if err != nil {
+		return PomProperties{}, err
+	}
+	return newPomProperties(props), nil
+}
+
+// ReadJarPomProperties reads the pom.properties that the Maven Archiver adds to META-INF/maven/<groupId>/<artifactId>
+// in a jar or war. Shaded jars also contain the pom.properties of their dependencies, so the one for the artifact
+// named like the jar is picked.
+func ReadJarPomProperties(jar string) (PomProperties, error) {
+	reader, err := zip.OpenReader(jar)
+	if err != nil {
+		return PomProperties{}, errors.New("unable to open Jar file")
+	}
+	defer reader.Close()
+
+	var found []PomProperties
+	for _, file := range reader.File {
+		if matched, _ := path.Match("META-INF/maven/*/*/pom.properties", file.Name); !matched {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return PomProperties{}, errors.New("unable to read Jar file")
+		}
+		props, err := ReadProperties(fileReader)
+		fileReader.Close()
+		if err != nil {
+			return PomProperties{}, fmt.Errorf("%s: %s", file.Name, err)
+		}
+
+		values := Properties{}
+		for _, prop := range props {
+			values[prop.Key] = prop.Value
+		}
+		found = append(found, newPomProperties(values))
+	}
+
+	name := filepath.Base(jar)
+	for _, pomProperties := range found {
+		if strings.HasPrefix(name, pomProperties.ArtifactId+"-"+pomProperties.Version) {
+			return pomProperties, nil
+		}
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return PomProperties{}, fmt.Errorf("could not find the pom.properties of %s", name)
+}
+
+// FindPomProperties returns the coordinates of an artifact, whose path is relative to the app directory, from the
+// target directory of its module, or from the artifact itself. It also returns where they were found.
+func FindPomProperties(appDir, artifact string) (PomProperties, string, error) {
+	// artifacts are in the target directory of their module
+	module := filepath.Dir(filepath.Dir(artifact))
+	if pomProperties, err := ReadPomProperties(appDir, module); err == nil && pomProperties.ArtifactId != "" {
+		return pomProperties, filepath.Join(module, "target", "maven-archiver", "pom.properties"), nil
+	}
+
+	pomProperties, err := ReadJarPomProperties(filepath.Join(appDir, artifact))
+	return pomProperties, artifact, err
+}
+
+func newPomProperties(props Properties) PomProperties {
+	return PomProperties{
+		GroupId:    strings.TrimSpace(props["groupId"]),
+		ArtifactId: strings.TrimSpace(props["artifactId"]),
+		Version:    strings.TrimSpace(props["version"]),
+	}
+}
+
+// Coordinates are groupId:artifactId:version.
+func (p PomProperties) Coordinates() string {
+	return fmt.Sprintf("%s:%s:%s", p.GroupId, p.ArtifactId, p.Version)
+}
@@ -0,0 +1,54 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestPom(t *testing.T) {
+	spec.Run(t, "Pom", testPom, spec.Report(report.Terminal{}))
+}
+
+func testPom(t *testing.T, when spec.G, it spec.S) {
+	when("#ReadPomProperties", func() {
+		it("should read the coordinates written by the Maven Archiver", func() {
+			pomProperties, err := util.ReadPomProperties(fixture("app_with_exec_jar"), ".")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := util.PomProperties{GroupId: "com.mycompany.app", ArtifactId: "my-app", Version: "1.0-SNAPSHOT"}
+			if pomProperties != expected {
+				t.Fatalf(`Did not read pom.properties: got %v, want %v`, pomProperties, expected)
+			}
+		})
+	})
+	when("#FindPomProperties", func() {
+		it("should prefer the target directory of the module", func() {
+			pomProperties, source, err := util.FindPomProperties(fixture("app_with_exec_jar"), "target/my-app-1.0-SNAPSHOT-jar-with-dependencies.jar")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := util.PomProperties{GroupId: "com.mycompany.app", ArtifactId: "my-app", Version: "1.0-SNAPSHOT"}
+			if pomProperties != expected || source != "target/maven-archiver/pom.properties" {
+				t.Fatalf(`Did not read pom.properties from the target directory: got %v from %s`, pomProperties, source)
+			}
+		})
+
+		it("should fall back to the pom.properties of the artifact itself", func() {
+			pomProperties, source, err := util.FindPomProperties(fixture("app_with_shaded_jar"), "target/demo-1.0.jar")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := util.PomProperties{GroupId: "com.example", ArtifactId: "demo", Version: "1.0"}
+			if pomProperties != expected || source != "target/demo-1.0.jar" {
+				t.Fatalf(`Did not read the pom.properties of the jar: got %v from %s`, pomProperties, source)
+			}
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+// ProcessKeyPrefix starts the system.properties keys that add a process type running another main class from the
+// app's jar, e.g. process.worker=com.example.Worker.
+const ProcessKeyPrefix = "process."
+
+// MainClassProcess is a process type that runs a main class from the app's jar.
+type MainClassProcess struct {
+	Type      string
+	MainClass string
+	Line      int
+}
+
+// ReadMainClassProcesses reads the process.* keys of system.properties, in the order they appear. A missing file has
+// no processes. Type names and classes are checked when the JDK is installed, so invalid entries are not expected.
+func ReadMainClassProcesses(appDir string) ([]MainClassProcess, error) {
+	file, err := os.Open(filepath.Join(appDir, "system.properties"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	props, err := ReadProperties(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []MainClassProcess
+	index := map[string]int{}
+	for _, prop := range props {
+		if !strings.HasPrefix(prop.Key, ProcessKeyPrefix) {
+			continue
+		}
+		process := MainClassProcess{
+			Type:      strings.TrimPrefix(prop.Key, ProcessKeyPrefix),
+			MainClass: strings.TrimSpace(prop.Value),
+			Line:      prop.Line,
+		}
+		// like any other property, the last value of a repeated key wins
+		if i, ok := index[process.Type]; ok {
+			processes[i] = process
+			continue
+		}
+		index[process.Type] = len(processes)
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+// IsValidProcessType checks a process type name: letters, digits, dashes and underscores, the same characters as in
+// manifest header names.
+func IsValidProcessType(name string) bool {
+	return isAlphanumericName(name)
+}
+
+// IsValidClassName checks that a name is a fully-qualified Java class name, such as com.example.Main or
+// com.example.Outer$Inner.
+func IsValidClassName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for i, c := range part {
+			isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c > 0x7f
+			if !isLetter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MainClassProcess runs another main class from the jar. Spring Boot jars keep the app's classes under BOOT-INF, so
+// they are run with the PropertiesLauncher, which is told the main class with loader.main.
+func (c JarCandidate) MainClassProcess(process MainClassProcess) layers.Process {
+	if c.IsSpringBoot() {
+		return layers.Process{
+			Type:    process.Type,
+			Command: fmt.Sprintf("java -Dloader.main=%s -cp %s %s", process.MainClass, c.Path, c.propertiesLauncher()),
+		}
+	}
+	return layers.Process{
+		Type:    process.Type,
+		Command: fmt.Sprintf("java -cp %s %s", c.Path, process.MainClass),
+	}
+}
+
+// propertiesLauncher is the PropertiesLauncher next to the jar's launcher, since Spring Boot 3.2 moved the launchers
+// from org.springframework.boot.loader to org.springframework.boot.loader.launch.
+func (c JarCandidate) propertiesLauncher() string {
+	launcherPackage := "org.springframework.boot.loader."
+	if strings.HasPrefix(c.Manifest.MainClass, launcherPackage) {
+		launcherPackage = c.Manifest.MainClass[:strings.LastIndex(c.Manifest.MainClass, ".")+1]
+	}
+	return launcherPackage + "PropertiesLauncher"
+}
+
+// WithMainClassProcesses adds the process types configured in system.properties to the detected ones. A configured
+// type replaces a detected one with the same name.
+func WithMainClassProcesses(detected layers.Processes, configured []MainClassProcess, command func(MainClassProcess) layers.Process) layers.Processes {
+	processes := append(layers.Processes{}, detected...)
+	for _, mainClassProcess := range configured {
+		process := command(mainClassProcess)
+		replaced := false
+		for i := range processes {
+			if processes[i].Type == process.Type {
+				processes[i] = process
+				replaced = true
+			}
+		}
+		if !replaced {
+			processes = append(processes, process)
+		}
+	}
+	return processes
+}
@@ -0,0 +1,115 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestProcesses(t *testing.T) {
+	spec.Run(t, "Processes", testProcesses, spec.Report(report.Terminal{}))
+}
+
+func testProcesses(t *testing.T, when spec.G, it spec.S) {
+	when("#ReadMainClassProcesses", func() {
+		it("should read the process types in order", func() {
+			configured, err := util.ReadMainClassProcesses(fixture("app_with_process_types"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(configured) != 2 || configured[0].Type != "worker" || configured[1].Type != "migrate" {
+				t.Fatalf(`Did not read the process types in order: %v`, configured)
+			}
+
+			if configured[1].MainClass != "com.example.db.Migrate" || configured[1].Line != 3 {
+				t.Fatalf(`Did not read the main class: %v`, configured[1])
+			}
+		})
+
+		it("should not need system.properties", func() {
+			configured, err := util.ReadMainClassProcesses(fixture("app_with_exec_jar"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(configured) != 0 {
+				t.Fatalf(`Did not expect process types: %v`, configured)
+			}
+		})
+	})
+
+	when("#WithMainClassProcesses", func() {
+		it("should run each main class from the jar alongside the web process", func() {
+			candidate := util.JarCandidate{Path: "target/app.jar", Manifest: util.Manifest{MainClass: "com.example.Main"}}
+			configured := []util.MainClassProcess{
+				{Type: "worker", MainClass: "com.example.Worker"},
+				{Type: "web", MainClass: "com.example.Web"},
+			}
+
+			processes := util.WithMainClassProcesses(layers.Processes{candidate.Process()}, configured, candidate.MainClassProcess)
+
+			expected := layers.Processes{
+				{Type: "web", Command: "java -cp target/app.jar com.example.Web"},
+				{Type: "worker", Command: "java -cp target/app.jar com.example.Worker"},
+			}
+			if len(processes) != len(expected) {
+				t.Fatalf(`Did not add the process types: got %v, want %v`, processes, expected)
+			}
+			for i := range expected {
+				if processes[i] != expected[i] {
+					t.Fatalf(`Did not create correct process: got %v, want %v`, processes[i], expected[i])
+				}
+			}
+		})
+
+		it("should use the PropertiesLauncher for Spring Boot jars", func() {
+			candidate := util.JarCandidate{Path: "target/demo.jar", Manifest: util.Manifest{
+				MainClass:  "org.springframework.boot.loader.JarLauncher",
+				StartClass: "com.example.DemoApplication",
+			}}
+
+			process := candidate.MainClassProcess(util.MainClassProcess{Type: "worker", MainClass: "com.example.Worker"})
+
+			expected := "java -Dloader.main=com.example.Worker -cp target/demo.jar org.springframework.boot.loader.PropertiesLauncher"
+			if process.Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, process.Command, expected)
+			}
+		})
+
+		it("should use the PropertiesLauncher from the launcher's package for Spring Boot 3.2 jars", func() {
+			candidate := util.JarCandidate{Path: "target/demo.jar", Manifest: util.Manifest{
+				MainClass:  "org.springframework.boot.loader.launch.JarLauncher",
+				StartClass: "com.example.DemoApplication",
+			}}
+
+			process := candidate.MainClassProcess(util.MainClassProcess{Type: "worker", MainClass: "com.example.Worker"})
+
+			expected := "java -Dloader.main=com.example.Worker -cp target/demo.jar org.springframework.boot.loader.launch.PropertiesLauncher"
+			if process.Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, process.Command, expected)
+			}
+		})
+	})
+
+	when("#IsValidClassName", func() {
+		it("should accept fully-qualified class names only", func() {
+			for name, valid := range map[string]bool{
+				"com.example.Main":        true,
+				"Main":                    true,
+				"com.example.Outer$Inner": true,
+				"com..Main":               false,
+				"com.example.1Main":       false,
+				"com.example.Main.":       false,
+				"":                        false,
+			} {
+				if util.IsValidClassName(name) != valid {
+					t.Fatalf(`Unexpected result for %q: want %t`, name, valid)
+				}
+			}
+		})
+	})
+}
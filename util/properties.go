@@ -2,12 +2,265 @@ package util
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type Properties map[string]string
 
+// Property is a single key/value pair read from a properties file. Line is the line the entry starts on, which is
+// the first line of the entry when it spans several lines with backslash continuations.
+type Property struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+type PropertiesError struct {
+	Line int
+	Err  error
+}
+
+func (e *PropertiesError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// PropertiesScanner reads entries from a properties file one at a time, following the format documented for
+// java.util.Properties#load(Reader). Input is expected to be UTF-8, which is a superset of the ASCII-plus-\uXXXX
+// files that Properties#store writes.
+type PropertiesScanner struct {
+	reader   *bufio.Reader
+	line     int
+	property Property
+	err      error
+}
+
+func NewPropertiesScanner(r io.Reader) *PropertiesScanner {
+	return &PropertiesScanner{reader: bufio.NewReader(r)}
+}
+
+// Scan advances to the next entry, skipping blank lines and comments. It returns false at the end of the input or
+// on the first error, which is then available from Err.
+func (s *PropertiesScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	logical, start, err := s.readLogicalLine()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+
+	key, value, err := splitPropertyLine(logical)
+	if err != nil {
+		s.err = &PropertiesError{Line: start, Err: err}
+		return false
+	}
+
+	s.property = Property{Key: key, Value: value, Line: start}
+	return true
+}
+
+func (s *PropertiesScanner) Property() Property {
+	return s.property
+}
+
+func (s *PropertiesScanner) Err() error {
+	return s.err
+}
+
+// readLogicalLine joins natural lines ending in an odd number of backslashes into one logical line, dropping the
+// leading whitespace of every natural line. Blank lines and comments are skipped entirely.
+func (s *PropertiesScanner) readLogicalLine() (string, int, error) {
+	var logical strings.Builder
+	start := 0
+
+	for {
+		natural, err := s.readNaturalLine()
+		if err != nil && natural == "" {
+			if err == io.EOF && start > 0 {
+				// a continuation at the very end of the input is dropped, like the JVM does
+				return logical.String(), start, nil
+			}
+			return "", 0, err
+		}
+
+		natural = strings.TrimLeft(natural, " \t\f")
+		if start == 0 {
+			if natural == "" || natural[0] == '#' || natural[0] == '!' {
+				if err == io.EOF {
+					return "", 0, err
+				}
+				continue
+			}
+			start = s.line
+		}
+
+		trailing := len(natural) - len(strings.TrimRight(natural, "\\"))
+		if trailing%2 == 1 {
+			logical.WriteString(natural[:len(natural)-1])
+			if err == io.EOF {
+				return logical.String(), start, nil
+			}
+			continue
+		}
+
+		logical.WriteString(natural)
+		return logical.String(), start, nil
+	}
+}
+
+// readNaturalLine reads up to the next \n, \r or \r\n terminator, which is not included in the result.
+func (s *PropertiesScanner) readNaturalLine() (string, error) {
+	var line strings.Builder
+	for {
+		r, _, err := s.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF && line.Len() > 0 {
+				s.line++
+				return line.String(), io.EOF
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\n':
+			s.line++
+			return line.String(), nil
+		case '\r':
+			if next, _, err := s.reader.ReadRune(); err == nil && next != '\n' {
+				_ = s.reader.UnreadRune()
+			}
+			s.line++
+			return line.String(), nil
+		default:
+			line.WriteRune(r)
+		}
+	}
+}
+
+// splitPropertyLine splits a logical line at the first unescaped '=', ':' or whitespace, and unescapes both halves.
+func splitPropertyLine(line string) (string, string, error) {
+	runes := []rune(line)
+
+	keyEnd := len(runes)
+	valueStart := len(runes)
+	escaped := false
+	for i, r := range runes {
+		if escaped {
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		if r == '=' || r == ':' || r == ' ' || r == '\t' || r == '\f' {
+			keyEnd = i
+			valueStart = skipSeparator(runes, i)
+			break
+		}
+	}
+
+	key, err := unescapeProperty(runes[:keyEnd])
+	if err != nil {
+		return "", "", err
+	}
+	value, err := unescapeProperty(runes[valueStart:])
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// skipSeparator skips whitespace, at most one '=' or ':', and any whitespace after it.
+func skipSeparator(runes []rune, i int) int {
+	for i < len(runes) && isPropertyWhitespace(runes[i]) {
+		i++
+	}
+	if i < len(runes) && (runes[i] == '=' || runes[i] == ':') {
+		i++
+	}
+	for i < len(runes) && isPropertyWhitespace(runes[i]) {
+		i++
+	}
+	return i
+}
+
+func isPropertyWhitespace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\f'
+}
+
+func unescapeProperty(runes []rune) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' {
+			out.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		if i >= len(runes) {
+			break
+		}
+		switch runes[i] {
+		case 't':
+			out.WriteRune('\t')
+		case 'n':
+			out.WriteRune('\n')
+		case 'r':
+			out.WriteRune('\r')
+		case 'f':
+			out.WriteRune('\f')
+		case 'u':
+			if i+4 >= len(runes) {
+				return "", errors.New(`malformed \uxxxx encoding`)
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 16)
+			if err != nil {
+				return "", errors.New(`malformed \uxxxx encoding`)
+			}
+			i += 4
+			// escapes of surrogate pairs are combined back into a single character
+			if utf16.IsSurrogate(rune(code)) && i+6 < len(runes) && runes[i+1] == '\\' && runes[i+2] == 'u' {
+				if low, err := strconv.ParseUint(string(runes[i+3:i+7]), 16, 16); err == nil {
+					if combined := utf16.DecodeRune(rune(code), rune(low)); combined != utf8.RuneError {
+						out.WriteRune(combined)
+						i += 6
+						continue
+					}
+				}
+			}
+			out.WriteRune(rune(code))
+		default:
+			out.WriteRune(runes[i])
+		}
+	}
+	return out.String(), nil
+}
+
+// ReadProperties reads every entry from r in the order they appear. Keys that are repeated keep each occurrence.
+func ReadProperties(r io.Reader) ([]Property, error) {
+	var props []Property
+	scanner := NewPropertiesScanner(r)
+	for scanner.Scan() {
+		props = append(props, scanner.Property())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
 func ReadPropertiesFile(filename string) (Properties, error) {
 	props := Properties{}
 
@@ -20,18 +273,10 @@ func ReadPropertiesFile(filename string) (Properties, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := NewPropertiesScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if equal := strings.Index(line, "="); equal >= 0 {
-			if key := strings.TrimSpace(line[:equal]); len(key) > 0 {
-				value := ""
-				if len(line) > equal {
-					value = strings.TrimSpace(line[equal+1:])
-				}
-				props[key] = value
-			}
-		}
+		p := scanner.Property()
+		props[p.Key] = p.Value
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -40,3 +285,55 @@ func ReadPropertiesFile(filename string) (Properties, error) {
 
 	return props, nil
 }
+
+// WriteProperties writes entries in the format read by java.util.Properties#load, escaping anything that would
+// otherwise be misread. Characters outside of printable ASCII are written as \uXXXX escapes, so the output is also
+// valid for Properties#load(InputStream).
+func WriteProperties(w io.Writer, props []Property) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range props {
+		if _, err := fmt.Fprintf(bw, "%s=%s\n", escapeProperty(p.Key, true), escapeProperty(p.Value, false)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func escapeProperty(s string, isKey bool) string {
+	var out strings.Builder
+	for i, r := range s {
+		switch r {
+		case ' ':
+			if i == 0 || isKey {
+				out.WriteString(`\ `)
+			} else {
+				out.WriteRune(r)
+			}
+		case '\\':
+			out.WriteString(`\\`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\f':
+			out.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			out.WriteRune('\\')
+			out.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				if r > 0xFFFF {
+					high, low := utf16.EncodeRune(r)
+					fmt.Fprintf(&out, `\u%04X\u%04X`, high, low)
+				} else {
+					fmt.Fprintf(&out, `\u%04X`, r)
+				}
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,157 @@
+package util_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestProperties(t *testing.T) {
+	spec.Run(t, "Properties", testProperties, spec.Report(report.Terminal{}))
+}
+
+func testProperties(t *testing.T, when spec.G, it spec.S) {
+	when("#ReadProperties", func() {
+		it("should read every separator and comment style", func() {
+			input := strings.Join([]string{
+				"# a comment with key=value",
+				"! another comment: with a colon",
+				"",
+				"equals=one",
+				"colon: two",
+				"space three",
+				"  padded  =  four  ",
+				"empty",
+				"a==b",
+			}, "\n")
+
+			props, err := util.ReadProperties(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := []util.Property{
+				{Key: "equals", Value: "one", Line: 4},
+				{Key: "colon", Value: "two", Line: 5},
+				{Key: "space", Value: "three", Line: 6},
+				{Key: "padded", Value: "four  ", Line: 7},
+				{Key: "empty", Value: "", Line: 8},
+				{Key: "a", Value: "=b", Line: 9},
+			}
+			if diff := cmp.Diff(props, expected); diff != "" {
+				t.Fatalf(`Properties did not match: (-got +want)\n%s`, diff)
+			}
+		})
+
+		it("should join continuation lines", func() {
+			input := "fruits = apple, banana, \\\n    pear\r\nodd\\\\=even\nnext=line"
+
+			props, err := util.ReadProperties(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := []util.Property{
+				{Key: "fruits", Value: "apple, banana, pear", Line: 1},
+				{Key: `odd\`, Value: "even", Line: 3},
+				{Key: "next", Value: "line", Line: 4},
+			}
+			if diff := cmp.Diff(props, expected); diff != "" {
+				t.Fatalf(`Properties did not match: (-got +want)\n%s`, diff)
+			}
+		})
+
+		it("should unescape keys and values", func() {
+			input := `key\ with\:seps\=x = tab\there é😀 \q`
+
+			props, err := util.ReadProperties(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := []util.Property{
+				{Key: "key with:seps=x", Value: "tab\there é😀 q", Line: 1},
+			}
+			if diff := cmp.Diff(props, expected); diff != "" {
+				t.Fatalf(`Properties did not match: (-got +want)\n%s`, diff)
+			}
+		})
+
+		it("should read unicode escapes", func() {
+			for input, expected := range map[string]string{
+				`key=\u0041\u00e9`:     "Aé",
+				`key=\u00E9t\u00E9`:    "été",
+				`key=\uD83D\uDE00`:     "😀",
+				`key=caf\u00e9 \u0021`: "café !",
+			} {
+				props, err := util.ReadProperties(strings.NewReader(input))
+				if err != nil {
+					t.Fatalf(`%s: %s`, input, err)
+				}
+
+				if len(props) != 1 || props[0].Value != expected {
+					t.Fatalf(`%s: value did not match: got %v, want %s`, input, props, expected)
+				}
+			}
+		})
+
+		it("should reject invalid and truncated unicode escapes", func() {
+			for input, line := range map[string]int{
+				"bad=\\u12x4":            1,
+				"bad=\\uGHIJ":            1,
+				"ok=1\nbad=\\u12":        2,
+				"ok=1\n\nbad=\\u":        3,
+				"ok=1\nbad=a\\\n  \\u00": 2,
+			} {
+				_, err := util.ReadProperties(strings.NewReader(input))
+
+				perr, ok := err.(*util.PropertiesError)
+				if !ok {
+					t.Fatalf(`%q: expected a PropertiesError: got %v`, input, err)
+				}
+				if perr.Line != line {
+					t.Fatalf(`%q: line did not match: got %d, want %d`, input, perr.Line, line)
+				}
+			}
+		})
+
+		it("should report malformed unicode escapes with a line number", func() {
+			_, err := util.ReadProperties(strings.NewReader("ok=1\nbad=\\u12x4"))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+
+			if perr, ok := err.(*util.PropertiesError); !ok || perr.Line != 2 {
+				t.Fatalf(`Error did not have a line number: %s`, err)
+			}
+		})
+	})
+
+	when("#WriteProperties", func() {
+		it("should write properties that read back the same", func() {
+			expected := []util.Property{
+				{Key: "key with spaces", Value: " leading space", Line: 1},
+				{Key: "seps=:#!", Value: "multi\nline\\path é😀", Line: 2},
+			}
+
+			var buf bytes.Buffer
+			if err := util.WriteProperties(&buf, expected); err != nil {
+				t.Fatal(err)
+			}
+
+			props, err := util.ReadProperties(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(props, expected); diff != "" {
+				t.Fatalf(`Properties did not match: (-got +want)\n%s`, diff)
+			}
+		})
+	})
+}
@@ -0,0 +1,295 @@
+package util
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	springBootLayerPrefix = "spring-boot-"
+	// the loader is only needed to run the jar with java -jar
+	springBootLoaderLayer = "spring-boot-loader"
+)
+
+// SpringBootLayer is a layer listed in the layers index of a Spring Boot jar. Entries are directories, which end with
+// a slash, or files.
+type SpringBootLayer struct {
+	Name    string
+	Entries []string
+}
+
+// SpringBootJar is what the indexes of a Spring Boot jar say about its contents.
+type SpringBootJar struct {
+	Version string
+	Classes string
+	Lib     string
+	Layers  []SpringBootLayer
+	// Classpath lists the jars in Lib in the order they should be on the classpath
+	Classpath []string
+}
+
+// IsSpringBoot is true for jars repackaged by the Spring Boot Maven plugin.
+func (c JarCandidate) IsSpringBoot() bool {
+	return c.Manifest.SpringBootVersion != "" ||
+		strings.HasPrefix(c.Manifest.MainClass, "org.springframework.boot.loader.")
+}
+
+// ReadSpringBootJar reads the layers and classpath indexes of a Spring Boot jar.
+func ReadSpringBootJar(jar string, manifest Manifest) (SpringBootJar, error) {
+	boot := SpringBootJar{
+		Version: manifest.SpringBootVersion,
+		Classes: manifest.Main.Get("Spring-Boot-Classes"),
+		Lib:     manifest.Main.Get("Spring-Boot-Lib"),
+	}
+	if boot.Classes == "" {
+		boot.Classes = "BOOT-INF/classes/"
+	}
+	if boot.Lib == "" {
+		boot.Lib = "BOOT-INF/lib/"
+	}
+
+	reader, err := zip.OpenReader(jar)
+	if err != nil {
+		return boot, errors.New("unable to open Jar file")
+	}
+	defer reader.Close()
+
+	layersIndex := manifest.Main.Get("Spring-Boot-Layers-Index")
+	classpathIndex := manifest.Main.Get("Spring-Boot-Classpath-Index")
+	for _, file := range reader.File {
+		switch {
+		case layersIndex != "" && file.Name == layersIndex:
+			if boot.Layers, err = readSpringBootLayersIndex(file); err != nil {
+				return boot, err
+			}
+		case classpathIndex != "" && file.Name == classpathIndex:
+			if boot.Classpath, err = readSpringBootClasspathIndex(file, boot.Lib); err != nil {
+				return boot, err
+			}
+		}
+	}
+
+	// without a classpath index, the jars are in the order the plugin wrote them
+	if boot.Classpath == nil {
+		for _, file := range reader.File {
+			if strings.HasPrefix(file.Name, boot.Lib) && strings.HasSuffix(file.Name, ".jar") {
+				boot.Classpath = append(boot.Classpath, file.Name)
+			}
+		}
+	}
+	return boot, nil
+}
+
+// IsLayered is true when the jar has a layers index, so that it can be split into separate layers.
+func (b SpringBootJar) IsLayered() bool {
+	return len(b.Layers) > 0
+}
+
+// readSpringBootLayersIndex parses BOOT-INF/layers.idx, a YAML list in which each layer is a line such as
+// `- "dependencies":`, followed by its entries indented on their own lines, such as `  - "BOOT-INF/lib/"`.
+func readSpringBootLayersIndex(file *zip.File) ([]SpringBootLayer, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var bootLayers []SpringBootLayer
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		item := strings.TrimPrefix(strings.TrimSpace(line), "- ")
+		switch {
+		case item == "":
+		case strings.HasPrefix(line, "- ") && strings.HasSuffix(line, ":"):
+			bootLayers = append(bootLayers, SpringBootLayer{Name: strings.Trim(strings.TrimSuffix(item, ":"), `"`)})
+		case strings.HasPrefix(line, " ") && item != strings.TrimSpace(line) && len(bootLayers) > 0:
+			last := &bootLayers[len(bootLayers)-1]
+			last.Entries = append(last.Entries, strings.Trim(item, `"`))
+		default:
+			return nil, fmt.Errorf("%s: invalid line %q", file.Name, line)
+		}
+	}
+	return bootLayers, scanner.Err()
+}
+
+// readSpringBootClasspathIndex parses BOOT-INF/classpath.idx, which lists either the paths of the jars, or only their
+// names in older versions.
+func readSpringBootClasspathIndex(file *zip.File, lib string) ([]string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var classpath []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		entry := strings.Trim(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "- "), `"`)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			entry = lib + entry
+		}
+		classpath = append(classpath, entry)
+	}
+	return classpath, scanner.Err()
+}
+
+// layerFor finds the layer an entry of the jar belongs to, which is the first one that lists it.
+func (b SpringBootJar) layerFor(name string) string {
+	for _, bootLayer := range b.Layers {
+		for _, entry := range bootLayer.Entries {
+			if name == entry || (strings.HasSuffix(entry, "/") && strings.HasPrefix(name, entry)) {
+				return bootLayer.Name
+			}
+		}
+	}
+	return ""
+}
+
+// ExplodedJar is a Spring Boot jar extracted into launch layers, which is run without its loader.
+type ExplodedJar struct {
+	StartClass string
+	Classpath  []string
+}
+
+// Process runs the Start-Class from the extracted layers.
+func (e ExplodedJar) Process() layers.Process {
+	return layers.Process{
+		Type:    "web",
+		Command: fmt.Sprintf("java -Dserver.port=$PORT $JAVA_OPTS -cp %s %s", strings.Join(e.Classpath, ":"), e.StartClass),
+	}
+}
+
+// MainClassProcess runs another main class from the extracted layers.
+func (e ExplodedJar) MainClassProcess(process MainClassProcess) layers.Process {
+	return layers.Process{
+		Type:    process.Type,
+		Command: fmt.Sprintf("java $JAVA_OPTS -cp %s %s", strings.Join(e.Classpath, ":"), process.MainClass),
+	}
+}
+
+// ExplodeSpringBootJar extracts each layer of a layered Spring Boot jar into its own launch layer, so that the
+// dependencies, which rarely change, end up in a different image layer from the application. The classpath follows
+// the classpath index, with each jar in the layer it was extracted to.
+func ExplodeSpringBootJar(appDir string, candidate JarCandidate, layersDir layers.Layers) (ExplodedJar, error) {
+	// the metadata of launch layers is restored from the previous image, which would otherwise keep them
+	if err := RemoveSpringBootLayers(layersDir); err != nil {
+		return ExplodedJar{}, err
+	}
+
+	jar := filepath.Join(appDir, candidate.Path)
+	boot, err := ReadSpringBootJar(jar, candidate.Manifest)
+	if err != nil {
+		return ExplodedJar{}, err
+	}
+	if !boot.IsLayered() {
+		return ExplodedJar{}, errors.New("the jar has no layers index")
+	}
+	if candidate.Manifest.StartClass == "" {
+		return ExplodedJar{}, errors.New("the jar has no Start-Class")
+	}
+	if candidate.Manifest.LauncherAgentClass != "" {
+		return ExplodedJar{}, errors.New("the jar has a Launcher-Agent-Class, which requires java -jar")
+	}
+
+	reader, err := zip.OpenReader(jar)
+	if err != nil {
+		return ExplodedJar{}, errors.New("unable to open Jar file")
+	}
+	defer reader.Close()
+
+	extracted := map[string]bool{}
+	for _, file := range reader.File {
+		bootLayer := boot.layerFor(file.Name)
+		if bootLayer == "" || springBootLayerPrefix+bootLayer == springBootLoaderLayer {
+			continue
+		}
+		if err := extractZipEntry(file, layersDir.Layer(springBootLayerPrefix+bootLayer).Root); err != nil {
+			return ExplodedJar{}, err
+		}
+		extracted[bootLayer] = true
+	}
+
+	for _, bootLayer := range boot.Layers {
+		if !extracted[bootLayer.Name] {
+			continue
+		}
+		metadata := struct {
+			SpringBootVersion string `toml:"spring_boot_version"`
+			Jar               string `toml:"jar"`
+		}{boot.Version, candidate.Path}
+		if err := layersDir.Layer(springBootLayerPrefix+bootLayer.Name).WriteMetadata(metadata, layers.Launch); err != nil {
+			return ExplodedJar{}, err
+		}
+	}
+
+	classesLayer := boot.layerFor(boot.Classes)
+	if classesLayer == "" {
+		return ExplodedJar{}, fmt.Errorf("%s is not in any layer", boot.Classes)
+	}
+	classpath := []string{filepath.Join(layersDir.Layer(springBootLayerPrefix+classesLayer).Root, boot.Classes)}
+	for _, lib := range boot.Classpath {
+		bootLayer := boot.layerFor(lib)
+		if bootLayer == "" {
+			return ExplodedJar{}, fmt.Errorf("%s is not in any layer", lib)
+		}
+		classpath = append(classpath, filepath.Join(layersDir.Layer(springBootLayerPrefix+bootLayer).Root, lib))
+	}
+
+	return ExplodedJar{StartClass: candidate.Manifest.StartClass, Classpath: classpath}, nil
+}
+
+// RemoveSpringBootLayers removes the layers extracted by a previous build, along with their metadata, since the jar
+// might have had other layers.
+func RemoveSpringBootLayers(layersDir layers.Layers) error {
+	existing, err := filepath.Glob(filepath.Join(layersDir.Root, springBootLayerPrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(file *zip.File, dest string) error {
+	target := filepath.Join(dest, filepath.FromSlash(file.Name))
+	if !strings.HasPrefix(target, filepath.Clean(dest)+string(filepath.Separator)) {
+		return fmt.Errorf("invalid entry %s", file.Name)
+	}
+	if file.FileInfo().IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	in, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,105 @@
+package util_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
+	"github.com/heroku/java-buildpack/util"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSpringBoot(t *testing.T) {
+	spec.Run(t, "SpringBoot", testSpringBoot, spec.Report(report.Terminal{}))
+}
+
+func testSpringBoot(t *testing.T, when spec.G, it spec.S) {
+	var layersDir layers.Layers
+
+	it.Before(func() {
+		root, err := ioutil.TempDir("", "layers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		layersDir = layers.NewLayers(root, logger.DefaultLogger())
+	})
+
+	it.After(func() {
+		os.RemoveAll(layersDir.Root)
+	})
+
+	when("#ExplodeSpringBootJar", func() {
+		it("should run the Start-Class from a launch layer per Spring Boot layer", func() {
+			appDir := fixture("app_with_spring_boot_layers")
+			candidate, err := util.SelectJar(appDir, ".", logger.Logger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !candidate.IsSpringBoot() {
+				t.Fatal("Did not detect a Spring Boot jar")
+			}
+
+			// left over from a build whose jar had other layers
+			if err := layersDir.Layer("spring-boot-old").WriteMetadata(struct{}{}, layers.Launch); err != nil {
+				t.Fatal(err)
+			}
+
+			exploded, err := util.ExplodeSpringBootJar(appDir, candidate, layersDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			process := exploded.Process()
+			dependencies := layersDir.Layer("spring-boot-dependencies").Root
+			snapshots := layersDir.Layer("spring-boot-snapshot-dependencies").Root
+			application := layersDir.Layer("spring-boot-application").Root
+			expected := "java -Dserver.port=$PORT $JAVA_OPTS -cp " + strings.Join([]string{
+				filepath.Join(application, "BOOT-INF/classes"),
+				filepath.Join(dependencies, "BOOT-INF/lib/spring-core-5.3.12.jar"),
+				filepath.Join(dependencies, "BOOT-INF/lib/slf4j-api-1.7.32.jar"),
+				filepath.Join(snapshots, "BOOT-INF/lib/demo-lib-0.0.1-SNAPSHOT.jar"),
+			}, ":") + " com.example.demo.DemoApplication"
+			if process.Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, process.Command, expected)
+			}
+
+			for _, file := range []string{
+				filepath.Join(dependencies, "BOOT-INF/lib/spring-core-5.3.12.jar"),
+				filepath.Join(snapshots, "BOOT-INF/lib/demo-lib-0.0.1-SNAPSHOT.jar"),
+				filepath.Join(application, "BOOT-INF/classes/application.properties"),
+				filepath.Join(layersDir.Root, "spring-boot-application.toml"),
+			} {
+				if _, err := os.Stat(file); err != nil {
+					t.Fatalf(`Did not extract %s: %s`, file, err)
+				}
+			}
+
+			for _, path := range []string{
+				layersDir.Layer("spring-boot-loader").Root,
+				filepath.Join(layersDir.Root, "spring-boot-old.toml"),
+			} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Fatalf(`Did not expect %s`, path)
+				}
+			}
+		})
+
+		it("should refuse jars without a layers index", func() {
+			appDir := fixture("app_with_exec_war")
+			candidate, err := util.SelectJar(appDir, ".", logger.Logger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := util.ExplodeSpringBootJar(appDir, candidate, layersDir); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package war
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	errorFmt = `
+%s
+  Caused by: %s
+
+We're sorry this build is failing! If you can't find the issue in application code,
+please submit a ticket so we can help: https://help.heroku.com/
+`
+)
+
+func errorWithCause(message string, cause error) error {
+	return errors.New(fmt.Sprintf(errorFmt, message, cause))
+}
+
+func unsupportedRunner(name string) error {
+	return errorWithCause(fmt.Sprintf("Unsupported WAR_RUNNER %s", name), fmt.Errorf("expected %s or %s", WebappRunner, JettyRunner))
+}
+
+func failedToInstallRunner(runner Runner, cause error) error {
+	return errorWithCause(fmt.Sprintf("Failed to install %s %s", runner.Name, runner.Version), cause)
+}
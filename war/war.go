@@ -0,0 +1,194 @@
+package war
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
+	"github.com/heroku/java-buildpack/util"
+)
+
+const (
+	WebappRunner = "webapp-runner"
+	JettyRunner  = "jetty-runner"
+
+	runnerLayerName = "war_runner"
+	mavenCentral    = "https://repo1.maven.org/maven2"
+)
+
+// httpClient downloads the runner jars and their checksums, which shouldn't hang the build when the repository stops
+// responding.
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// runnerArtifacts are the Maven coordinates and default version of each supported runner.
+var runnerArtifacts = map[string]struct {
+	GroupId        string
+	DefaultVersion string
+}{
+	WebappRunner: {"com.heroku", "9.0.52.1"},
+	JettyRunner:  {"org.eclipse.jetty", "9.4.53.v20231009"},
+}
+
+// Runner is a servlet container packaged as an executable jar, which is stored in the metadata of its launch layer.
+type Runner struct {
+	Name    string `toml:"name"`
+	Version string `toml:"version"`
+	Url     string `toml:"url"`
+	Sha256  string `toml:"sha256"`
+	Jar     string `toml:"jar"`
+}
+
+// Config selects the runner with WAR_RUNNER (webapp-runner by default, or jetty-runner) and its version with
+// WAR_RUNNER_VERSION. WAR_RUNNER_URL downloads the jar from elsewhere, such as a mirror of Maven Central, which must
+// also serve its .sha1 checksum.
+func Config() (Runner, error) {
+	name := os.Getenv("WAR_RUNNER")
+	if name == "" {
+		name = WebappRunner
+	}
+	artifact, ok := runnerArtifacts[name]
+	if !ok {
+		return Runner{}, unsupportedRunner(name)
+	}
+
+	version := os.Getenv("WAR_RUNNER_VERSION")
+	if version == "" {
+		version = artifact.DefaultVersion
+	}
+
+	runnerUrl := os.Getenv("WAR_RUNNER_URL")
+	if runnerUrl == "" {
+		runnerUrl = fmt.Sprintf("%s/%s/%s/%s/%s-%s.jar",
+			mavenCentral, strings.Replace(artifact.GroupId, ".", "/", -1), name, version, name, version)
+	}
+
+	return Runner{Name: name, Version: version, Url: runnerUrl}, nil
+}
+
+// Install downloads the runner into a launch layer, which is also cached so that the download only happens again when
+// the runner or its version changes.
+func Install(runner Runner, layersDir layers.Layers, log logger.Logger) (Runner, error) {
+	layer := layersDir.Layer(runnerLayerName)
+	runner.Jar = filepath.Join(layer.Root, runner.Name+".jar")
+
+	var cached Runner
+	if err := layer.ReadMetadata(&cached); err != nil {
+		log.Debug("%s", err)
+	}
+	if cached.Name == runner.Name && cached.Version == runner.Version && cached.Url == runner.Url {
+		if _, err := os.Stat(cached.Jar); err == nil {
+			log.Info("Using cached %s %s", cached.Name, cached.Version)
+			return cached, nil
+		}
+	}
+
+	log.Info("Installing %s %s", runner.Name, runner.Version)
+	if err := os.RemoveAll(layer.Root); err != nil {
+		return runner, failedToInstallRunner(runner, err)
+	}
+	if err := os.MkdirAll(layer.Root, 0755); err != nil {
+		return runner, failedToInstallRunner(runner, err)
+	}
+
+	checksum, err := download(runner.Url, runner.Jar)
+	if err != nil {
+		return runner, failedToInstallRunner(runner, err)
+	}
+	if err := verify(runner.Url, checksum); err != nil {
+		os.RemoveAll(layer.Root)
+		return runner, failedToInstallRunner(runner, err)
+	}
+	runner.Sha256 = checksum.sha256
+
+	if err := layer.WriteMetadata(runner, layers.Launch, layers.Cache); err != nil {
+		return runner, failedToInstallRunner(runner, err)
+	}
+	return runner, nil
+}
+
+// Remove removes the runner's layer, for a build that doesn't deploy a war. Otherwise, the runner of a previous build
+// would stay in the image.
+func Remove(layersDir layers.Layers) error {
+	layer := layersDir.Layer(runnerLayerName)
+	if err := layer.RemoveMetadata(); err != nil {
+		return err
+	}
+	return os.RemoveAll(layer.Root)
+}
+
+// Process deploys the war with the runner, listening on $PORT. Both runners take the same arguments.
+func (r Runner) Process(war util.JarCandidate) layers.Process {
+	return layers.Process{
+		Type:    "web",
+		Command: fmt.Sprintf("java -jar %s --port $PORT %s", r.Jar, war.Path),
+	}
+}
+
+type checksums struct {
+	sha1   string
+	sha256 string
+}
+
+// download writes the response to a file, and returns its checksums.
+func download(url, filename string) (checksums, error) {
+	resp, err := get(url)
+	if err != nil {
+		return checksums{}, err
+	}
+	defer resp.Body.Close()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return checksums{}, err
+	}
+	defer file.Close()
+
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, sha1Hash, sha256Hash), resp.Body); err != nil {
+		return checksums{}, err
+	}
+	return checksums{sha1: hex.EncodeToString(sha1Hash.Sum(nil)), sha256: hex.EncodeToString(sha256Hash.Sum(nil))}, nil
+}
+
+// verify compares the SHA-1 checksum of the jar with the .sha1 file that Maven repositories publish next to every
+// artifact.
+func verify(url string, actual checksums) error {
+	resp, err := get(url + ".sha1")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	// the file holds the checksum, optionally followed by the file name
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 || !strings.EqualFold(fields[0], actual.sha1) {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, actual.sha1, strings.Join(fields, " "))
+	}
+	return nil
+}
+
+func get(url string) (*http.Response, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response %s from %s", resp.Status, url)
+	}
+	return resp, nil
+}
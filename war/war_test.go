@@ -0,0 +1,190 @@
+package war_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/libbuildpack/layers"
+	"github.com/buildpack/libbuildpack/logger"
+	"github.com/heroku/java-buildpack/util"
+	"github.com/heroku/java-buildpack/war"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestWar(t *testing.T) {
+	spec.Run(t, "War", testWar, spec.Report(report.Terminal{}))
+}
+
+func testWar(t *testing.T, when spec.G, it spec.S) {
+	when("#Config", func() {
+		it.After(func() {
+			os.Unsetenv("WAR_RUNNER")
+			os.Unsetenv("WAR_RUNNER_VERSION")
+		})
+
+		it("should download webapp-runner from Maven Central by default", func() {
+			runner, err := war.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if runner.Name != war.WebappRunner {
+				t.Fatalf(`Did not default to webapp-runner: got %s`, runner.Name)
+			}
+
+			expected := fmt.Sprintf("https://repo1.maven.org/maven2/com/heroku/webapp-runner/%s/webapp-runner-%s.jar", runner.Version, runner.Version)
+			if runner.Url != expected {
+				t.Fatalf(`Did not use the Maven Central URL: got %s, want %s`, runner.Url, expected)
+			}
+		})
+
+		it("should use the configured runner and version", func() {
+			os.Setenv("WAR_RUNNER", "jetty-runner")
+			os.Setenv("WAR_RUNNER_VERSION", "9.4.44.v20210927")
+
+			runner, err := war.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := "https://repo1.maven.org/maven2/org/eclipse/jetty/jetty-runner/9.4.44.v20210927/jetty-runner-9.4.44.v20210927.jar"
+			if runner.Url != expected {
+				t.Fatalf(`Did not use the configured runner: got %s, want %s`, runner.Url, expected)
+			}
+		})
+
+		it("should reject unknown runners", func() {
+			os.Setenv("WAR_RUNNER", "tomcat")
+
+			if _, err := war.Config(); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	})
+
+	when("#Install", func() {
+		var (
+			layersDir layers.Layers
+			server    *httptest.Server
+			downloads int
+			// sha1 of "runner"
+			sha1 = "63a5fd3bc5f45a0490e4deca178d288050e26803"
+		)
+
+		it.Before(func() {
+			root, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			layersDir = layers.NewLayers(root, logger.Logger{})
+
+			downloads = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, ".sha1") {
+					fmt.Fprintf(w, "%s  %s\n", sha1, strings.TrimSuffix(filepath.Base(r.URL.Path), ".sha1"))
+					return
+				}
+				downloads++
+				fmt.Fprint(w, "runner")
+			}))
+		})
+
+		it.After(func() {
+			server.Close()
+			os.RemoveAll(layersDir.Root)
+		})
+
+		it("should install the runner into a cached launch layer and record its version", func() {
+			runner := war.Runner{Name: war.WebappRunner, Version: "9.0.52.1", Url: server.URL + "/webapp-runner.jar"}
+
+			installed, err := war.Install(runner, layersDir, logger.Logger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jar := filepath.Join(layersDir.Layer("war_runner").Root, "webapp-runner.jar")
+			if installed.Jar != jar {
+				t.Fatalf(`Did not install the jar in the layer: got %s, want %s`, installed.Jar, jar)
+			}
+
+			// sha256 of "runner"
+			if installed.Sha256 != "527aa9f431539da8e151d5434d1d5e611d973f601d8e970790882624554146b0" {
+				t.Fatalf(`Did not record the checksum: got %s`, installed.Sha256)
+			}
+
+			var metadata war.Runner
+			if err := layersDir.Layer("war_runner").ReadMetadata(&metadata); err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Version != "9.0.52.1" {
+				t.Fatalf(`Did not record the version: got %s, want %s`, metadata.Version, "9.0.52.1")
+			}
+
+			process := installed.Process(util.JarCandidate{Path: "target/demo-1.0.war"})
+			expected := fmt.Sprintf("java -jar %s --port $PORT target/demo-1.0.war", jar)
+			if process.Type != "web" || process.Command != expected {
+				t.Fatalf(`Did not create correct command: got %s, want %s`, process.Command, expected)
+			}
+
+			if _, err := war.Install(runner, layersDir, logger.Logger{}); err != nil {
+				t.Fatal(err)
+			}
+			if downloads != 1 {
+				t.Fatalf(`Did not reuse the cached runner: %d downloads`, downloads)
+			}
+
+			runner.Version = "9.0.56.0"
+			if _, err := war.Install(runner, layersDir, logger.Logger{}); err != nil {
+				t.Fatal(err)
+			}
+			if downloads != 2 {
+				t.Fatalf(`Did not download the new version: %d downloads`, downloads)
+			}
+		})
+
+		it("should fail when the checksum does not match", func() {
+			sha1 = "0000000000000000000000000000000000000000"
+			runner := war.Runner{Name: war.WebappRunner, Version: "9.0.52.1", Url: server.URL + "/webapp-runner.jar"}
+
+			if _, err := war.Install(runner, layersDir, logger.Logger{}); err == nil {
+				t.Fatal("Expected an error")
+			}
+			if _, err := os.Stat(layersDir.Layer("war_runner").Root); !os.IsNotExist(err) {
+				t.Fatal("Did not remove the unverified runner")
+			}
+		})
+	})
+
+	when("#Remove", func() {
+		it("should remove the runner layer", func() {
+			root, err := ioutil.TempDir("", "layers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			layersDir := layers.NewLayers(root, logger.Logger{})
+
+			layer := layersDir.Layer("war_runner")
+			if err := layer.WriteMetadata(war.Runner{Name: war.WebappRunner}, layers.Launch, layers.Cache); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := war.Remove(layersDir); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(layer.Root); !os.IsNotExist(err) {
+				t.Fatal("Did not remove the layer")
+			}
+			if _, err := os.Stat(layer.Root + ".toml"); !os.IsNotExist(err) {
+				t.Fatal("Did not remove the layer metadata")
+			}
+		})
+	})
+}